@@ -0,0 +1,27 @@
+import { check } from "k6";
+import tracing from "k6/x/tracing";
+
+export const options = {
+  vus: 1,
+  iterations: 1,
+};
+
+// Unlike instrumentHTTP, a client leaves the k6/http module untouched:
+// only the requests made through the client carry tracing headers.
+const client = new tracing.Client({
+  propagator: "jaeger",
+  sampling: 0.5,
+  baggage: { tenant: "acme" },
+});
+
+export default () => {
+  let res = client.get("http://httpbin.org/get");
+  check(res, {
+    "status is 200": (r) => r.status === 200,
+  });
+
+  res = client.post("http://httpbin.org/post", JSON.stringify({ name: "Bert" }));
+  check(res, {
+    "status is 200": (r) => r.status === 200,
+  });
+};
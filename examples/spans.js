@@ -0,0 +1,38 @@
+import http from "k6/http";
+import { check, group } from "k6";
+import tracing from "k6/x/tracing";
+
+export const options = {
+  vus: 1,
+  iterations: 1,
+};
+
+// The spans recorded by the last iteration are exported before k6 exits.
+// When running with --no-summary, also pass `--out tracing` to keep that
+// guarantee.
+tracing.instrumentHTTP({
+  propagator: "w3c",
+  exporter: { protocol: "http", endpoint: "http://localhost:4318/v1/traces" },
+});
+
+// Produce a span per group() call.
+tracing.instrumentGroups();
+
+export default () => {
+  const journey = tracing.startSpan("checkout", {
+    attributes: { "user.tier": "premium" },
+  });
+
+  // Requests made while the span is active become its children.
+  let res = http.get("http://httpbin.org/get");
+  journey.addEvent("cart loaded", { items: 3 });
+
+  group("payment", () => {
+    res = http.post("http://httpbin.org/post", JSON.stringify({ items: 3 }));
+    if (!check(res, { "status is 200": (r) => r.status === 200 })) {
+      journey.setStatus("error", "checkout failed");
+    }
+  });
+
+  journey.end();
+};
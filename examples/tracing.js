@@ -8,7 +8,7 @@ export const options = {
 };
 
 tracing.instrumentHTTP({
-  sampling: 12,
+  sampling: 0.5,
   propagator: "w3c",
   baggage: { "X-My-baggage": "some other thing" },
 });
@@ -4,8 +4,14 @@ package tracing
 import (
 	"github.com/grafana/xk6-tracing/tracing"
 	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/output"
 )
 
 func init() {
-	modules.Register("k6/x/tracing", new(tracing.RootModule))
+	// The tracing output shuts down the span processors of the module
+	// at the end of the test, and thus shares its root module.
+	root := tracing.New()
+
+	modules.Register("k6/x/tracing", root)
+	output.RegisterExtension(tracing.OutputName, root.NewOutput)
 }
@@ -0,0 +1,229 @@
+package tracing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// BaggageHeaderName is the name of the W3C baggage header
+	BaggageHeaderName = "baggage"
+
+	// BaggageMaxMembers is the maximum number of list-members a W3C baggage
+	// header is allowed to hold.
+	BaggageMaxMembers = 64
+
+	// BaggageMaxBytes is the maximum size, in bytes, of a W3C baggage header.
+	BaggageMaxBytes = 8192
+
+	// JaegerBaggageHeaderPrefix is the prefix of the headers used to propagate
+	// baggage items with the Jaeger propagation format.
+	JaegerBaggageHeaderPrefix = "uberctx-"
+
+	// B3BaggageHeaderPrefix is the prefix of the headers used to propagate
+	// baggage items alongside the B3 propagation format.
+	B3BaggageHeaderPrefix = "baggage-"
+
+	// OTBaggageHeaderPrefix is the prefix of the headers used to propagate
+	// baggage items with the OpenTracing propagation format, which the
+	// Datadog propagation format reuses.
+	OTBaggageHeaderPrefix = "ot-baggage-"
+)
+
+// Baggage is a set of user-defined key-value pairs propagated alongside
+// the trace context, as defined by the W3C baggage specification.
+type Baggage []BaggageMember
+
+// BaggageMember is a single baggage entry.
+type BaggageMember struct {
+	Key        string
+	Value      string
+	Properties []BaggageProperty
+}
+
+// BaggageProperty is an optional piece of metadata attached to a baggage member.
+//
+// A property with an empty value is propagated as a key-only property.
+type BaggageProperty struct {
+	Key   string
+	Value string
+}
+
+// NewBaggage builds a Baggage from the baggage option, as it was
+// passed by the user.
+//
+// Each item value is either a string, or an object holding a `value`
+// string and an optional `properties` object of string values, such as:
+//
+//	{ scenario: "checkout", tenant: { value: "acme", properties: { ttl: "60" } } }
+//
+// Members are sorted by key, and the resulting baggage is validated against
+// the constraints of the W3C baggage specification.
+func NewBaggage(items map[string]interface{}) (Baggage, error) {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	baggage := make(Baggage, 0, len(items))
+	for _, key := range keys {
+		member, err := newBaggageMember(key, items[key])
+		if err != nil {
+			return nil, err
+		}
+
+		baggage = append(baggage, member)
+	}
+
+	if err := baggage.validate(); err != nil {
+		return nil, err
+	}
+
+	return baggage, nil
+}
+
+// newBaggageMember builds a BaggageMember from a single baggage option item.
+func newBaggageMember(key string, item interface{}) (BaggageMember, error) {
+	member := BaggageMember{Key: key}
+
+	switch v := item.(type) {
+	case string:
+		member.Value = v
+	case map[string]interface{}:
+		value, ok := v["value"].(string)
+		if !ok {
+			return member, fmt.Errorf("baggage item %q value must be a string", key)
+		}
+		member.Value = value
+
+		rawProperties, ok := v["properties"]
+		if !ok || rawProperties == nil {
+			break
+		}
+
+		properties, ok := rawProperties.(map[string]interface{})
+		if !ok {
+			return member, fmt.Errorf("baggage item %q properties must be an object", key)
+		}
+
+		propertyKeys := make([]string, 0, len(properties))
+		for propertyKey := range properties {
+			propertyKeys = append(propertyKeys, propertyKey)
+		}
+		sort.Strings(propertyKeys)
+
+		for _, propertyKey := range propertyKeys {
+			propertyValue, ok := properties[propertyKey].(string)
+			if !ok {
+				return member, fmt.Errorf("baggage item %q property %q must be a string", key, propertyKey)
+			}
+
+			member.Properties = append(member.Properties, BaggageProperty{Key: propertyKey, Value: propertyValue})
+		}
+	default:
+		return member, fmt.Errorf("baggage item %q must be either a string or an object", key)
+	}
+
+	return member, nil
+}
+
+// validate ensures the baggage complies with the W3C baggage specification's
+// keys format, and size limits.
+func (b Baggage) validate() error {
+	if len(b) > BaggageMaxMembers {
+		return fmt.Errorf("baggage holds %d items, exceeding the maximum of %d", len(b), BaggageMaxMembers)
+	}
+
+	for _, member := range b {
+		if !isToken(member.Key) {
+			return fmt.Errorf("baggage key %q is not a valid token", member.Key)
+		}
+
+		for _, property := range member.Properties {
+			if !isToken(property.Key) {
+				return fmt.Errorf("baggage item %q property key %q is not a valid token", member.Key, property.Key)
+			}
+		}
+	}
+
+	if size := len(b.String()); size > BaggageMaxBytes {
+		return fmt.Errorf("baggage is %d bytes long, exceeding the maximum of %d", size, BaggageMaxBytes)
+	}
+
+	return nil
+}
+
+// String returns the baggage encoded in the W3C baggage header format.
+func (b Baggage) String() string {
+	members := make([]string, 0, len(b))
+
+	for _, member := range b {
+		var sb strings.Builder
+		sb.WriteString(member.Key)
+		sb.WriteByte('=')
+		sb.WriteString(percentEncodeBaggageValue(member.Value))
+
+		for _, property := range member.Properties {
+			sb.WriteByte(';')
+			sb.WriteString(property.Key)
+			if property.Value != "" {
+				sb.WriteByte('=')
+				sb.WriteString(percentEncodeBaggageValue(property.Value))
+			}
+		}
+
+		members = append(members, sb.String())
+	}
+
+	return strings.Join(members, ",")
+}
+
+// percentEncodeBaggageValue percent-encodes any byte of the given value which
+// is not a valid baggage-octet, as defined by the W3C baggage specification.
+//
+// The percent sign itself is always encoded, so that the value can be
+// unambiguously decoded by the receiving end.
+func percentEncodeBaggageValue(value string) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if isBaggageOctet(c) && c != '%' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		sb.WriteByte('%')
+		sb.WriteByte(hexDigits[c>>4])
+		sb.WriteByte(hexDigits[c&0x0F])
+	}
+
+	return sb.String()
+}
+
+// isBaggageOctet returns true if the given byte is a valid baggage-octet,
+// that is any printable US-ASCII character, excluding whitespace, double
+// quotes, commas, semicolons and backslashes.
+func isBaggageOctet(c byte) bool {
+	return c >= 0x21 && c <= 0x7E && c != '"' && c != ',' && c != ';' && c != '\\'
+}
+
+// isToken returns true if the given string is a valid token, as defined by RFC 7230.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		isAlphaNum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlphaNum && !strings.ContainsRune("!#$%&'*+-.^_`|~", rune(c)) {
+			return false
+		}
+	}
+
+	return true
+}
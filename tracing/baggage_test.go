@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBaggage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("string items should be sorted by key", func(t *testing.T) {
+		t.Parallel()
+
+		baggage, err := NewBaggage(map[string]interface{}{
+			"scenario": "checkout",
+			"env":      "staging",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, Baggage{
+			{Key: "env", Value: "staging"},
+			{Key: "scenario", Value: "checkout"},
+		}, baggage)
+	})
+
+	t.Run("object items should hold a value and properties", func(t *testing.T) {
+		t.Parallel()
+
+		baggage, err := NewBaggage(map[string]interface{}{
+			"tenant": map[string]interface{}{
+				"value":      "acme",
+				"properties": map[string]interface{}{"ttl": "60", "internal": ""},
+			},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, Baggage{
+			{
+				Key:   "tenant",
+				Value: "acme",
+				Properties: []BaggageProperty{
+					{Key: "internal", Value: ""},
+					{Key: "ttl", Value: "60"},
+				},
+			},
+		}, baggage)
+	})
+
+	t.Run("nil items should produce an empty baggage", func(t *testing.T) {
+		t.Parallel()
+
+		baggage, err := NewBaggage(nil)
+
+		require.NoError(t, err)
+		assert.Empty(t, baggage)
+	})
+
+	t.Run("invalid key should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewBaggage(map[string]interface{}{"my key": "value"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("non-string value should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewBaggage(map[string]interface{}{"key": int64(42)})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("too many items should fail", func(t *testing.T) {
+		t.Parallel()
+
+		items := make(map[string]interface{}, BaggageMaxMembers+1)
+		for i := 0; i <= BaggageMaxMembers; i++ {
+			items[RandHexStringRunes(8)+"-key"] = "value"
+		}
+
+		_, err := NewBaggage(items)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("oversized baggage should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewBaggage(map[string]interface{}{"key": strings.Repeat("a", BaggageMaxBytes)})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestBaggageString(t *testing.T) {
+	t.Parallel()
+
+	baggage := Baggage{
+		{Key: "X-My-baggage", Value: "some other thing"},
+		{Key: "scenario", Value: "checkout;v=2,50%"},
+		{
+			Key:   "tenant",
+			Value: "acme",
+			Properties: []BaggageProperty{
+				{Key: "internal"},
+				{Key: "ttl", Value: "60 s"},
+			},
+		},
+	}
+
+	assert.Equal(t,
+		"X-My-baggage=some%20other%20thing,scenario=checkout%3Bv=2%2C50%25,tenant=acme;internal;ttl=60%20s",
+		baggage.String(),
+	)
+}
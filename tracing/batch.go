@@ -0,0 +1,356 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	k6http "go.k6.io/k6/js/modules/k6/http"
+	"go.k6.io/k6/lib/netext/httpext"
+	"go.k6.io/k6/metrics"
+)
+
+// batchRequestTagName is the name of the tag identifying the samples emitted
+// by each request of a batch.
+//
+// The http.batch method captures the VU's tags and metadata once for all the
+// requests it makes. Each request is thus tagged with its key in the batch,
+// which is replaced with the request's trace and span IDs metadata before
+// the samples reach the outputs. As the keys of a batch are set by the
+// script, the tag only ever holds a bounded set of values.
+const batchRequestTagName = "__tracing_batch_request"
+
+// instrumentHTTPBatch returns a new function that wraps the original
+// http.batch method, adding distinct tracing headers to each request.
+//
+// The produced resulting function is ready to be injected back into the http
+// module, in place of the original method.
+func (t *Tracing) instrumentHTTPBatch(batchFn goja.Callable) goja.Callable {
+	return func(requests goja.Value, args ...goja.Value) (goja.Value, error) {
+		rt := t.vu.Runtime()
+
+		tracedRequests, traces, err := t.tracedBatchRequests(requests)
+		if err != nil {
+			common.Throw(rt, fmt.Errorf("failed to instrument HTTP batch requests: %w", err))
+		}
+
+		vuState := t.vu.State()
+		if vuState == nil {
+			// Outside of the VU context, http.batch fails on its own,
+			// and we let it report the appropriate error.
+			return batchFn(goja.Undefined(), append([]goja.Value{tracedRequests}, args...)...)
+		}
+
+		// The VU's samples are only intercepted during the batch call,
+		// as http.batch only returns once all its requests have completed,
+		// and their samples have been emitted.
+		interceptor := newBatchInterceptor(t.vu.Context(), vuState.Samples, traces)
+		go interceptor.run()
+
+		vuState.Samples = interceptor.samples
+		result, err := batchFn(goja.Undefined(), append([]goja.Value{tracedRequests}, args...)...)
+		vuState.Samples = interceptor.output
+
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		t.recordHTTPBatchSpans(traces, result, time.Now())
+
+		return result, err
+	}
+}
+
+// batchInterceptor intercepts the samples emitted by a VU during a batch,
+// replacing the batch request tag of the samples emitted by the batch's
+// requests with their trace and span IDs metadata.
+type batchInterceptor struct {
+	// ctx is the VU's context at the time of the batch, bounding the
+	// lifetime of the interceptor.
+	ctx context.Context
+
+	// samples receives the samples emitted by the VU during the batch.
+	samples chan metrics.SampleContainer
+
+	// output is the VU's original samples channel, the intercepted
+	// samples are forwarded to.
+	output chan<- metrics.SampleContainer
+
+	// traces holds the trace context of the requests of the batch,
+	// indexed by their key in the batch.
+	traces map[string]*requestTrace
+}
+
+// newBatchInterceptor returns an interceptor forwarding the samples of the
+// batch whose requests hold the given trace contexts to the given output
+// channel.
+func newBatchInterceptor(
+	ctx context.Context, output chan<- metrics.SampleContainer, traces map[string]*requestTrace,
+) *batchInterceptor {
+	return &batchInterceptor{
+		ctx:     ctx,
+		samples: make(chan metrics.SampleContainer),
+		output:  output,
+		traces:  traces,
+	}
+}
+
+// run forwards the intercepted samples to the output channel, until the
+// VU's context is done.
+//
+// Asynchronous operations, such as websockets, can hold on to the samples
+// channel they found while the batch was ongoing, and keep emitting samples
+// after the batch completed. Such samples are forwarded untouched until the
+// iteration ends, by which point the operations have completed too.
+func (i *batchInterceptor) run() {
+	for {
+		select {
+		case container := <-i.samples:
+			if !metrics.PushIfNotDone(i.ctx, i.output, moveBatchTagsToMetadata(container, i.traces)) {
+				return
+			}
+		case <-i.ctx.Done():
+			return
+		}
+	}
+}
+
+// recordHTTPBatchSpans records the client spans of the requests of an
+// instrumented http.batch call, from the k6 responses it produced.
+//
+// As the batch requests are made concurrently, each request's span end time
+// is derived from its own timings breakdown, rather than from the time at
+// which the whole batch completed.
+func (t *Tracing) recordHTTPBatchSpans(traces map[string]*requestTrace, responses goja.Value, batchEndTime time.Time) {
+	if t.processor == nil || isNullish(responses) {
+		return
+	}
+
+	rt := t.vu.Runtime()
+	responsesObj := responses.ToObject(rt)
+
+	spans := make([]*Span, 0, len(traces))
+	for key, trace := range traces {
+		if !trace.IsSampled() {
+			continue
+		}
+
+		res, ok := responsesObj.Get(key).Export().(*k6http.Response)
+		if !ok || res.Response == nil {
+			continue
+		}
+
+		endTime := httpTimingsEndTime(trace.startTime, res.Response.Timings)
+		if endTime.After(batchEndTime) {
+			endTime = batchEndTime
+		}
+
+		spans = append(spans, newHTTPClientSpan(trace, res.Response, endTime))
+	}
+
+	if len(spans) > 0 {
+		t.exportSpans(spans...)
+	}
+}
+
+// tracedBatchRequests returns a copy of the http.batch requests argument,
+// in which each request carries its own tracing headers, and is tagged
+// with its key.
+//
+// The requests argument is either an array, or an object, of requests.
+// Each request is either an array, an object, or a plain URL.
+//
+// It also returns the trace context produced for each request, indexed by
+// the request's key in the requests argument.
+func (t *Tracing) tracedBatchRequests(requests goja.Value) (goja.Value, map[string]*requestTrace, error) {
+	rt := t.vu.Runtime()
+
+	if isNullish(requests) {
+		return requests, nil, nil
+	}
+
+	var traced *goja.Object
+	switch requests.Export().(type) {
+	case []interface{}:
+		traced = rt.NewArray()
+	case map[string]interface{}:
+		traced = rt.NewObject()
+	default:
+		// Let http.batch report the invalid argument type.
+		return requests, nil, nil
+	}
+
+	requestsObj := requests.ToObject(rt)
+	keys := requestsObj.Keys()
+	traces := make(map[string]*requestTrace, len(keys))
+
+	for _, key := range keys {
+		request, trace, err := t.tracedBatchRequest(key, requestsObj.Get(key))
+		if err != nil {
+			return nil, nil, fmt.Errorf("batch request %s: %w", key, err)
+		}
+
+		if err = traced.Set(key, request); err != nil {
+			return nil, nil, err
+		}
+
+		if trace != nil {
+			traces[key] = trace
+		}
+	}
+
+	return traced, traces, nil
+}
+
+// tracedBatchRequest returns a copy of a single http.batch request, whose
+// params carry tracing headers.
+//
+// Array requests are normalized to the [method, url, body, params] form,
+// object requests hold a params property, and plain URLs are converted
+// to GET array requests. Any other value is left untouched, for http.batch
+// to report it.
+//
+// The returned trace context is nil if the request was left untouched.
+func (t *Tracing) tracedBatchRequest(key string, request goja.Value) (goja.Value, *requestTrace, error) {
+	rt := t.vu.Runtime()
+
+	switch request.Export().(type) {
+	case []interface{}:
+		requestObj := request.ToObject(rt)
+
+		// Let http.batch report requests missing a method or url.
+		if requestObj.Get("length").ToInteger() < 2 {
+			return request, nil, nil
+		}
+
+		body := requestObj.Get("2")
+		if isNullish(body) {
+			body = goja.Null()
+		}
+
+		params, trace, err := t.tracedBatchParams(key, requestObj.Get("3"))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return rt.NewArray(requestObj.Get("0"), requestObj.Get("1"), body, params), trace, nil
+	case map[string]interface{}:
+		traced := copyObject(rt, request)
+
+		params, trace, err := t.tracedBatchParams(key, traced.Get("params"))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err = traced.Set("params", params); err != nil {
+			return nil, nil, err
+		}
+
+		return traced, trace, nil
+	case string, httpext.URL:
+		params, trace, err := t.tracedBatchParams(key, goja.Undefined())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return rt.NewArray(http.MethodGet, request, goja.Null(), params), trace, nil
+	default:
+		return request, nil, nil
+	}
+}
+
+// tracedBatchParams returns a copy of a batch request params, with tracing
+// headers, and the tag identifying the request's samples by its key.
+//
+// The params object, as well as its headers and tags, are copied rather than
+// modified in place, as the same params object is commonly shared by multiple
+// requests of a batch.
+func (t *Tracing) tracedBatchParams(key string, params goja.Value) (*goja.Object, *requestTrace, error) {
+	rt := t.vu.Runtime()
+
+	traced := copyObject(rt, params)
+
+	headers := copyObject(rt, traced.Get("headers"))
+	if err := traced.Set("headers", headers); err != nil {
+		return nil, nil, err
+	}
+
+	trace, err := t.injectTraceHeaders(headers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tags := copyObject(rt, traced.Get("tags"))
+	if err = tags.Set(batchRequestTagName, key); err != nil {
+		return nil, nil, err
+	}
+
+	if err = traced.Set("tags", tags); err != nil {
+		return nil, nil, err
+	}
+
+	return traced, trace, nil
+}
+
+// moveBatchTagsToMetadata replaces the batch request tag of the samples of
+// the given container with the trace and span IDs metadata of the request
+// it identifies.
+//
+// It returns the updated container, as single samples are containers
+// passed by value.
+func moveBatchTagsToMetadata(
+	container metrics.SampleContainer, traces map[string]*requestTrace,
+) metrics.SampleContainer {
+	if sample, ok := container.(metrics.Sample); ok {
+		sample.Tags, sample.Metadata = batchTagsToMetadata(sample.Tags, sample.Metadata, traces)
+		return sample
+	}
+
+	samples := container.GetSamples()
+	for i := range samples {
+		samples[i].Tags, samples[i].Metadata = batchTagsToMetadata(samples[i].Tags, samples[i].Metadata, traces)
+	}
+
+	// HTTP requests samples are emitted as trails, which also expose
+	// their tags and metadata to the outputs.
+	if trail, ok := container.(*httpext.Trail); ok && trail.Tags != nil {
+		trail.Tags, trail.Metadata = batchTagsToMetadata(trail.Tags, trail.Metadata, traces)
+	}
+
+	return container
+}
+
+// batchTagsToMetadata returns the given tags without the batch request tag,
+// along with a copy of the given metadata holding the trace and span IDs of
+// the request it identifies.
+//
+// The given metadata is returned as is when the tags hold no batch request
+// tag, or when the tagged request isn't part of the given traces.
+func batchTagsToMetadata(
+	tags *metrics.TagSet, metadata map[string]string, traces map[string]*requestTrace,
+) (*metrics.TagSet, map[string]string) {
+	key, ok := tags.Get(batchRequestTagName)
+	if !ok {
+		return tags, metadata
+	}
+
+	tags = tags.Without(batchRequestTagName)
+
+	trace, ok := traces[key]
+	if !ok {
+		return tags, metadata
+	}
+
+	moved := make(map[string]string, len(metadata)+2)
+	for metadataKey, value := range metadata {
+		moved[metadataKey] = value
+	}
+
+	moved[metadataTraceIDKeyName] = trace.TraceID
+	moved[metadataSpanIDKeyName] = trace.SpanID
+
+	return tags, moved
+}
@@ -0,0 +1,288 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib/netext/httpext"
+	"go.k6.io/k6/metrics"
+)
+
+func TestTracingTracedBatchRequests(t *testing.T) {
+	t.Parallel()
+
+	newTestTracing := func(t *testing.T) (*Tracing, *goja.Runtime) {
+		t.Helper()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU, propagator: &W3CPropagator{}, sampler: &ProbabilisticSampler{rate: 1}}
+
+		return tracing, testSetup.VU.Runtime()
+	}
+
+	// assertTracedParams asserts that the given params value holds
+	// a traceparent header, and is tagged with the given batch key.
+	assertTracedParams := func(t *testing.T, rt *goja.Runtime, params goja.Value, key string) {
+		t.Helper()
+
+		require.False(t, isNullish(params))
+		paramsObj := params.ToObject(rt)
+
+		headers := paramsObj.Get("headers")
+		require.False(t, isNullish(headers))
+		assert.False(t, isNullish(headers.ToObject(rt).Get(W3CHeaderName)))
+
+		tags := paramsObj.Get("tags")
+		require.False(t, isNullish(tags))
+		assert.Equal(t, key, tags.ToObject(rt).Get(batchRequestTagName).String())
+	}
+
+	t.Run("array of arrays should be normalized with distinct trace IDs", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, rt := newTestTracing(t)
+		requests, err := rt.RunString(`[["GET", "https://k6.io"], ["POST", "https://k6.io", "body", {headers: {"X-Foo": "bar"}}]]`)
+		require.NoError(t, err)
+
+		traced, traces, err := tracing.tracedBatchRequests(requests)
+		require.NoError(t, err)
+
+		var got [][]goja.Value
+		require.NoError(t, rt.ExportTo(traced, &got))
+		require.Len(t, got, 2)
+		require.Len(t, got[0], 4)
+		require.Len(t, got[1], 4)
+		assert.True(t, goja.IsNull(got[0][2]))
+		assert.Equal(t, "body", got[1][2].String())
+		assert.Equal(t, "bar", got[1][3].ToObject(rt).Get("headers").ToObject(rt).Get("X-Foo").String())
+
+		assertTracedParams(t, rt, got[0][3], "0")
+		assertTracedParams(t, rt, got[1][3], "1")
+		require.Len(t, traces, 2)
+		assert.NotEqual(t, traces["0"].TraceID, traces["1"].TraceID)
+	})
+
+	t.Run("array of objects should hold traced params", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, rt := newTestTracing(t)
+		requests, err := rt.RunString(`[{method: "GET", url: "https://k6.io"}, {url: "https://k6.io", params: {tags: {a: "b"}}}]`)
+		require.NoError(t, err)
+
+		traced, _, err := tracing.tracedBatchRequests(requests)
+		require.NoError(t, err)
+
+		var got []*goja.Object
+		require.NoError(t, rt.ExportTo(traced, &got))
+		require.Len(t, got, 2)
+		assert.Equal(t, "https://k6.io", got[0].Get("url").String())
+		assertTracedParams(t, rt, got[0].Get("params"), "0")
+		assertTracedParams(t, rt, got[1].Get("params"), "1")
+		assert.Equal(t, "b", got[1].Get("params").ToObject(rt).Get("tags").ToObject(rt).Get("a").String())
+	})
+
+	t.Run("object of requests and plain URLs should be normalized", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, rt := newTestTracing(t)
+		requests, err := rt.RunString(`({first: "https://k6.io", second: ["PUT", "https://k6.io"]})`)
+		require.NoError(t, err)
+
+		traced, _, err := tracing.tracedBatchRequests(requests)
+		require.NoError(t, err)
+
+		var got map[string][]goja.Value
+		require.NoError(t, rt.ExportTo(traced, &got))
+		require.Len(t, got, 2)
+		require.Len(t, got["first"], 4)
+		assert.Equal(t, "GET", got["first"][0].String())
+		assert.Equal(t, "https://k6.io", got["first"][1].String())
+		assertTracedParams(t, rt, got["first"][3], "first")
+		assertTracedParams(t, rt, got["second"][3], "second")
+	})
+
+	t.Run("shared params should be copied rather than modified", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, rt := newTestTracing(t)
+		requests, err := rt.RunString(`
+			var params = {headers: {"X-Foo": "bar"}};
+			[["GET", "https://k6.io", null, params], {url: "https://k6.io", params: params}]
+		`)
+		require.NoError(t, err)
+
+		_, _, err = tracing.tracedBatchRequests(requests)
+		require.NoError(t, err)
+
+		params := rt.Get("params").ToObject(rt)
+		assert.True(t, isNullish(params.Get("tags")))
+		assert.True(t, isNullish(params.Get("headers").ToObject(rt).Get(W3CHeaderName)))
+	})
+
+	t.Run("invalid requests should be left untouched", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, rt := newTestTracing(t)
+		requests, err := rt.RunString(`[42, null, ["GET"]]`)
+		require.NoError(t, err)
+
+		traced, traces, err := tracing.tracedBatchRequests(requests)
+		require.NoError(t, err)
+
+		var got []goja.Value
+		require.NoError(t, rt.ExportTo(traced, &got))
+		require.Len(t, got, 3)
+		assert.Equal(t, int64(42), got[0].Export())
+		assert.True(t, goja.IsNull(got[1]))
+		assert.Equal(t, []interface{}{"GET"}, got[2].Export())
+		assert.Empty(t, traces)
+	})
+}
+
+func TestTracingInstrumentHTTPBatch(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(srv.Close)
+
+	testSetup, _ := newTestClientRuntime(t)
+	rt := testSetup.VU.Runtime()
+	require.NoError(t, rt.Set("url", srv.URL))
+
+	_, err := rt.RunString(`const client = new tracing.Client({propagator: "w3c"})`)
+	require.NoError(t, err)
+
+	moveToTestVUContext(t, testSetup)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	testSetup.VU.CtxField = ctx
+
+	samples := make(chan metrics.SampleContainer, 1000)
+	testSetup.VU.StateField.Samples = samples
+
+	_, err = rt.RunString(`client.batch([url, url])`)
+	require.NoError(t, err)
+
+	// The VU's samples are only intercepted during the batch call.
+	assert.Equal(t, (chan<- metrics.SampleContainer)(samples), testSetup.VU.State().Samples)
+
+	spanIDs := make(map[string]bool)
+	for len(spanIDs) < 2 {
+		select {
+		case container := <-samples:
+			trail, ok := container.(*httpext.Trail)
+			require.True(t, ok)
+
+			_, tagged := trail.Tags.Get(batchRequestTagName)
+			assert.False(t, tagged)
+			assert.NotEmpty(t, trail.Metadata[metadataTraceIDKeyName])
+			spanIDs[trail.Metadata[metadataSpanIDKeyName]] = true
+		case <-time.After(time.Second):
+			t.Fatal("the batch requests samples should be forwarded")
+		}
+	}
+}
+
+func TestMoveBatchTagsToMetadata(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	metric := registry.MustNewMetric("test_metric", metrics.Counter)
+	metadata := map[string]string{"other": "value"}
+	traces := map[string]*requestTrace{
+		"first": {SpanContext: newTestSpanContext(testTraceID, testSpanID, true)},
+	}
+
+	newTrail := func(key string) *httpext.Trail {
+		tags := registry.RootTagSet().With("method", "GET").With(batchRequestTagName, key)
+
+		return &httpext.Trail{
+			Tags:     tags,
+			Metadata: metadata,
+			Samples: []metrics.Sample{
+				{TimeSeries: metrics.TimeSeries{Metric: metric, Tags: tags}, Metadata: metadata, Value: 1},
+			},
+		}
+	}
+
+	t.Run("tagged request should carry its trace metadata", func(t *testing.T) {
+		t.Parallel()
+
+		trail := newTrail("first")
+		moveBatchTagsToMetadata(trail, traces)
+
+		for _, container := range []metrics.SampleContainer{trail, trail.Samples[0]} {
+			sample := container.GetSamples()[0]
+			assert.Equal(t, map[string]string{"method": "GET"}, sample.Tags.Map())
+			assert.Equal(t, map[string]string{
+				"other":                "value",
+				metadataTraceIDKeyName: testTraceID,
+				metadataSpanIDKeyName:  testSpanID,
+			}, sample.Metadata)
+		}
+
+		assert.Equal(t, map[string]string{"method": "GET"}, trail.Tags.Map())
+		assert.Equal(t, testTraceID, trail.Metadata[metadataTraceIDKeyName])
+		assert.Equal(t, testSpanID, trail.Metadata[metadataSpanIDKeyName])
+		assert.NotContains(t, metadata, metadataTraceIDKeyName)
+	})
+
+	t.Run("unknown request should only lose its tag", func(t *testing.T) {
+		t.Parallel()
+
+		trail := newTrail("second")
+		moveBatchTagsToMetadata(trail, traces)
+
+		assert.Equal(t, map[string]string{"method": "GET"}, trail.Tags.Map())
+		assert.Equal(t, map[string]string{"method": "GET"}, trail.Samples[0].Tags.Map())
+		assert.Equal(t, metadata, trail.Metadata)
+	})
+}
+
+func TestBatchInterceptor(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	metric := registry.MustNewMetric("test_metric", metrics.Counter)
+	output := make(chan metrics.SampleContainer, 2)
+	traces := map[string]*requestTrace{
+		"0": {SpanContext: newTestSpanContext(testTraceID, testSpanID, true)},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interceptor := newBatchInterceptor(ctx, output, traces)
+
+	done := make(chan struct{})
+	go func() {
+		interceptor.run()
+		close(done)
+	}()
+
+	interceptor.samples <- metrics.Sample{
+		TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet().With(batchRequestTagName, "0")},
+	}
+	got := (<-output).GetSamples()[0]
+	assert.Equal(t, testTraceID, got.Metadata[metadataTraceIDKeyName])
+	_, tagged := got.Tags.Get(batchRequestTagName)
+	assert.False(t, tagged)
+
+	// Samples emitted by other operations are forwarded untouched.
+	interceptor.samples <- metrics.Sample{TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()}}
+	assert.Empty(t, (<-output).GetSamples()[0].Metadata)
+
+	// The interceptor stops once the VU's context is done.
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the interceptor should stop once the context is done")
+	}
+}
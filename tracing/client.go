@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// NewClient is the tracing.Client constructor, producing HTTP clients
+// that add tracing headers to the requests they make.
+//
+// Unlike InstrumentHTTP, it leaves the k6/http module untouched: only
+// the requests made through the client are instrumented, with its own
+// propagator, sampling rate, and baggage. The client exposes the same
+// methods as the k6/http module, which it calls on behalf of the script.
+//
+// The client's requests join the VU's active span, if any, as well as
+// the VU's iteration trace when the client's traces are scoped to
+// iterations.
+func (t *Tracing) NewClient(call goja.ConstructorCall) *goja.Object {
+	rt := t.vu.Runtime()
+
+	optionsValue := call.Argument(0)
+	if isNullish(optionsValue) {
+		common.Throw(rt, errors.New("tracing.Client requires options"))
+	}
+
+	var options instrumentationOptions
+	if err := rt.ExportTo(optionsValue, &options); err != nil {
+		common.Throw(rt, fmt.Errorf("invalid tracing.Client options: %w", err))
+	}
+
+	client := &Tracing{
+		vu:     t.vu,
+		root:   t.root,
+		parent: t.module(),
+	}
+
+	if err := client.configure(options); err != nil {
+		common.Throw(rt, err)
+	}
+
+	methods, err := t.k6HTTPMethods()
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	clientObj := rt.NewObject()
+	for method, tracedMethodFn := range client.instrumentHTTPMethods(methods) {
+		if err = clientObj.Set(string(method), tracedMethodFn); err != nil {
+			common.Throw(rt, err)
+		}
+	}
+
+	return clientObj
+}
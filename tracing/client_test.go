@@ -0,0 +1,329 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/oxtoacart/bpool"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k6http "go.k6.io/k6/js/modules/k6/http"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+	"gopkg.in/guregu/null.v3"
+)
+
+// newTestClientRuntime returns a test runtime exposing the tracing module
+// as the tracing global, and resolving the k6/http module with require.
+func newTestClientRuntime(t *testing.T) (*modulestest.Runtime, *goja.Object) {
+	t.Helper()
+
+	testSetup := modulestest.NewRuntime(t)
+	rt := testSetup.VU.Runtime()
+
+	mi, ok := New().NewModuleInstance(testSetup.VU).(*ModuleInstance)
+	require.True(t, ok)
+
+	httpModule := rt.ToValue(k6http.New().NewModuleInstance(testSetup.VU).Exports().Default).ToObject(rt)
+
+	require.NoError(t, rt.Set("require", func(string) goja.Value { return httpModule }))
+	// As k6 does, expose the module's named exports as an object's
+	// properties, which constructors require.
+	tracingModule := rt.NewObject()
+	for name, value := range mi.Exports().Named {
+		require.NoError(t, tracingModule.Set(name, value))
+	}
+
+	require.NoError(t, rt.Set("tracing", tracingModule))
+
+	return testSetup, httpModule
+}
+
+// moveToTestVUContext moves the given test runtime to the VU context,
+// with a state allowing it to make HTTP requests.
+func moveToTestVUContext(t *testing.T, testSetup *modulestest.Runtime) {
+	t.Helper()
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+
+	registry := testSetup.VU.InitEnv().Registry
+
+	testSetup.MoveToVUContext(&lib.State{
+		Options: lib.Options{
+			Throw:        null.BoolFrom(true),
+			SystemTags:   &metrics.DefaultSystemTagSet,
+			Batch:        null.IntFrom(20),
+			BatchPerHost: null.IntFrom(20),
+		},
+		Logger:         logrus.New(),
+		Group:          root,
+		Transport:      http.DefaultTransport,
+		BPool:          bpool.NewBufferPool(1),
+		Samples:        make(chan metrics.SampleContainer, 1000),
+		Tags:           lib.NewVUStateTags(registry.RootTagSet()),
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(registry),
+	})
+}
+
+func TestTracingNewClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("requests made through the client should carry tracing headers", func(t *testing.T) {
+		t.Parallel()
+
+		headers := make(chan http.Header, 10)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers <- r.Header.Clone()
+		}))
+		t.Cleanup(srv.Close)
+
+		testSetup, _ := newTestClientRuntime(t)
+		rt := testSetup.VU.Runtime()
+		require.NoError(t, rt.Set("url", srv.URL))
+
+		_, err := rt.RunString(`const client = new tracing.Client({propagator: "jaeger", baggage: {tenant: "acme"}})`)
+		require.NoError(t, err)
+
+		moveToTestVUContext(t, testSetup)
+
+		_, err = rt.RunString(`
+			client.get(url);
+			client.post(url, "body", {headers: {"X-User": "bob"}});
+			client.batch([url]);
+		`)
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			got := <-headers
+			assert.NotEmpty(t, got.Get(JaegerHeaderName))
+			assert.Equal(t, "acme", got.Get("Uberctx-Tenant"))
+		}
+	})
+
+	t.Run("requests should carry the headers of every listed propagator", func(t *testing.T) {
+		t.Parallel()
+
+		headers := make(chan http.Header, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers <- r.Header.Clone()
+		}))
+		t.Cleanup(srv.Close)
+
+		testSetup, _ := newTestClientRuntime(t)
+		rt := testSetup.VU.Runtime()
+		require.NoError(t, rt.Set("url", srv.URL))
+
+		_, err := rt.RunString(`const client = new tracing.Client({propagator: ["w3c", "b3"]})`)
+		require.NoError(t, err)
+
+		moveToTestVUContext(t, testSetup)
+
+		_, err = rt.RunString(`client.get(url)`)
+		require.NoError(t, err)
+
+		got := <-headers
+		traceparent := strings.Split(got.Get(W3CHeaderName), "-")
+		require.Len(t, traceparent, 4)
+		assert.Equal(t, traceparent[1]+"-"+traceparent[2]+"-1", got.Get(B3HeaderName))
+	})
+
+	t.Run("requests should carry the configured trace state", func(t *testing.T) {
+		t.Parallel()
+
+		headers := make(chan http.Header, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers <- r.Header.Clone()
+		}))
+		t.Cleanup(srv.Close)
+
+		testSetup, _ := newTestClientRuntime(t)
+		rt := testSetup.VU.Runtime()
+		require.NoError(t, rt.Set("url", srv.URL))
+
+		_, err := rt.RunString(`const client = new tracing.Client({propagator: "w3c", traceState: "k6=1, vendor=value"})`)
+		require.NoError(t, err)
+
+		moveToTestVUContext(t, testSetup)
+
+		_, err = rt.RunString(`client.get(url)`)
+		require.NoError(t, err)
+
+		got := <-headers
+		assert.NotEmpty(t, got.Get(W3CHeaderName))
+		assert.Equal(t, "k6=1,vendor=value", got.Get(W3CTraceStateHeaderName))
+	})
+
+	t.Run("requests should carry the headers of propagator functions", func(t *testing.T) {
+		t.Parallel()
+
+		headers := make(chan http.Header, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers <- r.Header.Clone()
+		}))
+		t.Cleanup(srv.Close)
+
+		testSetup, _ := newTestClientRuntime(t)
+		rt := testSetup.VU.Runtime()
+		require.NoError(t, rt.Set("url", srv.URL))
+
+		_, err := rt.RunString(`const client = new tracing.Client({
+			propagator: ["w3c", (ctx) => ({"X-Req-Trace": ctx.traceId + "/" + ctx.spanId})],
+		})`)
+		require.NoError(t, err)
+
+		moveToTestVUContext(t, testSetup)
+
+		_, err = rt.RunString(`client.get(url)`)
+		require.NoError(t, err)
+
+		got := <-headers
+		traceparent := strings.Split(got.Get(W3CHeaderName), "-")
+		require.Len(t, traceparent, 4)
+		assert.Equal(t, traceparent[1]+"/"+traceparent[2], got.Get("X-Req-Trace"))
+	})
+
+	t.Run("failed requests should not leak their trace metadata", func(t *testing.T) {
+		t.Parallel()
+
+		srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		url := srv.URL
+		srv.Close()
+
+		testSetup, _ := newTestClientRuntime(t)
+		rt := testSetup.VU.Runtime()
+		require.NoError(t, rt.Set("url", url))
+
+		_, err := rt.RunString(`const client = new tracing.Client({propagator: "w3c"})`)
+		require.NoError(t, err)
+
+		moveToTestVUContext(t, testSetup)
+
+		_, err = rt.RunString(`client.get(url)`)
+		require.Error(t, err)
+
+		metadata := testSetup.VU.State().Tags.GetCurrentValues().Metadata
+		assert.NotContains(t, metadata, metadataTraceIDKeyName)
+		assert.NotContains(t, metadata, metadataSpanIDKeyName)
+	})
+
+	t.Run("the http module should be left untouched", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup, httpModule := newTestClientRuntime(t)
+		original := httpModule.Get(string(k6HTTPGetMethodName))
+
+		_, err := testSetup.VU.Runtime().RunString(`new tracing.Client({propagator: "w3c"})`)
+
+		require.NoError(t, err)
+		assert.True(t, original.SameAs(httpModule.Get(string(k6HTTPGetMethodName))))
+	})
+
+	t.Run("clients should expose the k6/http methods", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup, _ := newTestClientRuntime(t)
+
+		got, err := testSetup.VU.Runtime().RunString(`
+			const client = new tracing.Client({propagator: "w3c"});
+			["get", "post", "put", "patch", "del", "head", "options", "request", "batch"]
+				.every((method) => typeof client[method] === "function")
+		`)
+
+		require.NoError(t, err)
+		assert.True(t, got.ToBoolean())
+	})
+
+	t.Run("clients should only expose asyncRequest when k6/http does", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup, _ := newTestClientRuntime(t)
+
+		got, err := testSetup.VU.Runtime().RunString(`
+			typeof new tracing.Client({propagator: "w3c"}).asyncRequest === "undefined"
+		`)
+
+		require.NoError(t, err)
+		assert.True(t, got.ToBoolean())
+	})
+
+	t.Run("asyncRequest should carry the trace context of its request", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup, httpModule := newTestClientRuntime(t)
+		rt := testSetup.VU.Runtime()
+
+		// As http.asyncRequest does, the fake captures the VU's metadata
+		// synchronously, and settles its promise asynchronously.
+		var (
+			gotMetadata map[string]string
+			gotHeader   string
+		)
+		require.NoError(t, httpModule.Set(string(k6HTTPAsyncRequestMethodName),
+			func(method, url string, body goja.Value, params *goja.Object) *goja.Promise {
+				gotMetadata = testSetup.VU.State().Tags.GetCurrentValues().Metadata
+				gotHeader = params.Get("headers").ToObject(rt).Get(W3CHeaderName).String()
+
+				promise, resolve, _ := rt.NewPromise()
+				callback := testSetup.VU.RegisterCallback()
+				go callback(func() error {
+					resolve("response")
+					return nil
+				})
+
+				return promise
+			},
+		))
+
+		_, err := rt.RunString(`const client = new tracing.Client({propagator: "w3c"})`)
+		require.NoError(t, err)
+
+		moveToTestVUContext(t, testSetup)
+
+		err = testSetup.EventLoop.Start(func() error {
+			_, err := rt.RunString(`
+				var result;
+				client.asyncRequest("GET", "https://k6.io").then((res) => { result = res; });
+			`)
+			return err
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "response", rt.Get("result").String())
+		require.Contains(t, gotMetadata, metadataTraceIDKeyName)
+		require.Contains(t, gotMetadata, metadataSpanIDKeyName)
+		assert.Equal(t,
+			W3CVersion+"-"+gotMetadata[metadataTraceIDKeyName]+"-"+gotMetadata[metadataSpanIDKeyName]+"-"+W3CSampledTraceFlag,
+			gotHeader,
+		)
+
+		// The metadata doesn't leak into the requests made once the
+		// promise settled.
+		assert.NotContains(t, testSetup.VU.State().Tags.GetCurrentValues().Metadata, metadataTraceIDKeyName)
+	})
+
+	t.Run("missing options should throw", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup, _ := newTestClientRuntime(t)
+
+		_, err := testSetup.VU.Runtime().RunString(`new tracing.Client()`)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("an unknown propagator should throw", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup, _ := newTestClientRuntime(t)
+
+		_, err := testSetup.VU.Runtime().RunString(`new tracing.Client({propagator: "unknown"})`)
+
+		assert.Error(t, err)
+	})
+}
@@ -1,9 +1,23 @@
 package tracing
 
 import (
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"math/rand"
 )
 
+// randomHexString returns the hex encoding of n cryptographically
+// random bytes.
+func randomHexString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := cryptorand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
 // RandHexStringRunes returns a random string of n hex characters.
 //
 // Note that this function uses a non-cryptographic random number generator.
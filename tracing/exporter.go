@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+)
+
+// SpanExporter is an interface for exporting spans to a tracing backend.
+//
+// Shutdown releases the resources held by the exporter, such as its
+// connections. It is called once, after the last export.
+type SpanExporter interface {
+	ExportSpans(ctx context.Context, spans []*Span) error
+	Shutdown(ctx context.Context) error
+}
+
+const (
+	// OTLPHTTPExporterProtocol is the protocol name of the OTLP/HTTP exporter.
+	OTLPHTTPExporterProtocol = "http"
+
+	// OTLPGRPCExporterProtocol is the protocol name of the OTLP/gRPC exporter.
+	OTLPGRPCExporterProtocol = "grpc"
+
+	// ZipkinExporterProtocol is the protocol name of the Zipkin v2 JSON exporter.
+	ZipkinExporterProtocol = "zipkin"
+
+	// CompressionNone disables the compression of exported spans.
+	CompressionNone = "none"
+
+	// CompressionGzip compresses exported spans using gzip.
+	CompressionGzip = "gzip"
+
+	// DefaultServiceName is the service name spans are reported under,
+	// unless explicitly configured otherwise.
+	DefaultServiceName = "k6"
+)
+
+// exporterOptions are the options that can be passed to the
+// tracing.instrumentHTTP() method's exporter option.
+type exporterOptions struct {
+	// Protocol is the protocol to export spans with, either http, grpc,
+	// zipkin, or file. Defaults to http.
+	Protocol string `js:"protocol"`
+
+	// Endpoint is the address of the tracing backend spans are exported to.
+	Endpoint string `js:"endpoint"`
+
+	// Path is the path of the file spans are written to by the file protocol.
+	Path string `js:"path"`
+
+	// Headers are additional headers to send along with the exported spans.
+	// With the grpc protocol, they are sent as gRPC metadata.
+	Headers map[string]string `js:"headers"`
+
+	// Insecure disables the transport security of the grpc protocol.
+	// With the http protocol, security is defined by the endpoint's scheme.
+	Insecure bool `js:"insecure"`
+
+	// Compression is the compression applied to exported spans, either
+	// none, or gzip. Defaults to none. The zipkin protocol only supports none.
+	Compression string `js:"compression"`
+
+	// ServiceName is the name of the service spans are reported under.
+	// Defaults to k6.
+	ServiceName string `js:"serviceName"`
+
+	// Batch tunes how spans are batched before being exported.
+	Batch *batchOptions `js:"batch"`
+}
+
+// newSpanExporter returns a new SpanExporter for the given options.
+func newSpanExporter(opts exporterOptions) (SpanExporter, error) {
+	if opts.ServiceName == "" {
+		opts.ServiceName = DefaultServiceName
+	}
+
+	switch opts.Protocol {
+	case "", OTLPHTTPExporterProtocol:
+		return newOTLPHTTPExporter(opts)
+	case OTLPGRPCExporterProtocol:
+		return newOTLPGRPCExporter(opts)
+	case ZipkinExporterProtocol:
+		return newZipkinExporter(opts)
+	case FileExporterProtocol:
+		return newFileExporter(opts)
+	default:
+		return nil, fmt.Errorf("unknown exporter protocol: %s", opts.Protocol)
+	}
+}
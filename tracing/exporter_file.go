@@ -0,0 +1,193 @@
+package tracing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// FileExporterProtocol is the protocol name of the file exporter.
+const FileExporterProtocol = "file"
+
+// fileExporter is a SpanExporter writing spans to a local file, as
+// OTLP-JSON lines: each export appends a single line holding an OTLP
+// export request.
+//
+// The file can be replayed into any OTLP compatible backend, for instance
+// using the OpenTelemetry Collector's otlpjsonfile receiver.
+type fileExporter struct {
+	path        string
+	serviceName string
+	gzip        bool
+
+	// mu serializes the writes of the exporter to the file.
+	mu sync.Mutex
+}
+
+// newFileExporter returns a new fileExporter for the given options.
+//
+// The file is created if it doesn't exist, and appended to otherwise,
+// so that the exporters of all VUs can share it.
+func newFileExporter(opts exporterOptions) (*fileExporter, error) {
+	if opts.Path == "" {
+		return nil, errors.New("the file protocol requires a path")
+	}
+
+	var useGzip bool
+	switch opts.Compression {
+	case "", CompressionNone:
+	case CompressionGzip:
+		useGzip = true
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", opts.Compression)
+	}
+
+	f, err := openSpansFile(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err = f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close spans file: %w", err)
+	}
+
+	return &fileExporter{
+		path:        opts.Path,
+		serviceName: opts.ServiceName,
+		gzip:        useGzip,
+	}, nil
+}
+
+// ExportSpans appends the given spans to the configured file.
+//
+// With gzip compression, each export is written as a distinct gzip member,
+// which concatenated form a valid gzip file.
+func (e *fileExporter) ExportSpans(_ context.Context, spans []*Span) error {
+	request, err := newOTLPTraceRequest(e.serviceName, spans)
+	if err != nil {
+		return err
+	}
+
+	line, err := encodeOTLPJSON(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+	line = append(line, '\n')
+
+	if e.gzip {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err = gz.Write(line); err != nil {
+			return fmt.Errorf("failed to compress spans: %w", err)
+		}
+		if err = gz.Close(); err != nil {
+			return fmt.Errorf("failed to compress spans: %w", err)
+		}
+		line = buf.Bytes()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	f, err := openSpansFile(e.path)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(line); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write spans: %w", err)
+	}
+
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("failed to close spans file: %w", err)
+	}
+
+	return nil
+}
+
+// Shutdown implements the SpanExporter interface. As the file is only
+// opened for the duration of each export, there's nothing to release.
+func (e *fileExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// openSpansFile opens the file at the given path for appending,
+// creating it if necessary.
+func openSpansFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spans file: %w", err)
+	}
+
+	return f, nil
+}
+
+// otlpJSONIDKeys are the keys of the OTLP-JSON span fields holding IDs.
+var otlpJSONIDKeys = []string{"traceId", "spanId", "parentSpanId"}
+
+// encodeOTLPJSON encodes an OTLP export request using the OTLP-JSON
+// encoding, on a single line.
+//
+// OTLP-JSON differs from the canonical protobuf JSON mapping in that trace
+// and span IDs are hex, rather than base64, encoded, and enums are encoded
+// as integers.
+func encodeOTLPJSON(request *coltracepb.ExportTraceServiceRequest) ([]byte, error) {
+	encoded, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	if err = decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	for _, resourceSpans := range jsonObjects(document["resourceSpans"]) {
+		for _, scopeSpans := range jsonObjects(resourceSpans["scopeSpans"]) {
+			for _, span := range jsonObjects(scopeSpans["spans"]) {
+				for _, key := range otlpJSONIDKeys {
+					id, ok := span[key].(string)
+					if !ok {
+						continue
+					}
+
+					raw, decodeErr := base64.StdEncoding.DecodeString(id)
+					if decodeErr != nil {
+						return nil, fmt.Errorf("invalid %s %q: %w", key, id, decodeErr)
+					}
+
+					span[key] = hex.EncodeToString(raw)
+				}
+			}
+		}
+	}
+
+	return json.Marshal(document)
+}
+
+// jsonObjects returns the objects held by a decoded JSON array,
+// ignoring any other value.
+func jsonObjects(v interface{}) []map[string]interface{} {
+	array, _ := v.([]interface{})
+
+	objects := make([]map[string]interface{}, 0, len(array))
+	for _, item := range array {
+		if object, ok := item.(map[string]interface{}); ok {
+			objects = append(objects, object)
+		}
+	}
+
+	return objects
+}
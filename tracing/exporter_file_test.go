@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileExporterExportSpans(t *testing.T) {
+	t.Parallel()
+
+	t.Run("each export should append an OTLP-JSON line", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "spans.jsonl")
+		exporter, err := newSpanExporter(exporterOptions{Protocol: FileExporterProtocol, Path: path})
+		require.NoError(t, err)
+
+		now := time.Unix(1_600_000_000, 0)
+		for _, name := range []string{"first", "second"} {
+			err = exporter.ExportSpans(context.Background(), []*Span{{
+				TraceID:      testTraceID,
+				SpanID:       testSpanID,
+				ParentSpanID: "0102030405060708",
+				Name:         name,
+				Kind:         SpanKindClient,
+				StartTime:    now,
+				EndTime:      now,
+			}})
+			require.NoError(t, err)
+		}
+
+		f, err := os.Open(path) //nolint:gosec
+		require.NoError(t, err)
+		defer func() { _ = f.Close() }()
+
+		lines := readOTLPJSONLines(t, bufio.NewScanner(f))
+		require.Len(t, lines, 2)
+
+		span := lines[1]["resourceSpans"].([]interface{})[0].(map[string]interface{})["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0]
+		assert.Equal(t, map[string]interface{}{
+			"traceId":           testTraceID,
+			"spanId":            testSpanID,
+			"parentSpanId":      "0102030405060708",
+			"name":              "second",
+			"kind":              float64(SpanKindClient),
+			"startTimeUnixNano": "1600000000000000000",
+			"endTimeUnixNano":   "1600000000000000000",
+			"status":            map[string]interface{}{},
+		}, span)
+	})
+
+	t.Run("gzip compression should produce a valid gzip file", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "spans.jsonl.gz")
+		exporter, err := newSpanExporter(exporterOptions{Protocol: FileExporterProtocol, Path: path, Compression: CompressionGzip})
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			err = exporter.ExportSpans(context.Background(), []*Span{{TraceID: testTraceID, SpanID: testSpanID}})
+			require.NoError(t, err)
+		}
+
+		f, err := os.Open(path) //nolint:gosec
+		require.NoError(t, err)
+		defer func() { _ = f.Close() }()
+
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+
+		assert.Len(t, readOTLPJSONLines(t, bufio.NewScanner(gz)), 2)
+	})
+
+	t.Run("missing path should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newSpanExporter(exporterOptions{Protocol: FileExporterProtocol})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("unwritable path should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newSpanExporter(exporterOptions{
+			Protocol: FileExporterProtocol,
+			Path:     filepath.Join(t.TempDir(), "missing", "spans.jsonl"),
+		})
+
+		assert.Error(t, err)
+	})
+}
+
+func readOTLPJSONLines(t *testing.T, scanner *bufio.Scanner) []map[string]interface{} {
+	t.Helper()
+
+	var lines []map[string]interface{}
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.NoError(t, scanner.Err())
+
+	return lines
+}
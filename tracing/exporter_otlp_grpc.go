@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// DefaultOTLPGRPCEndpoint is the endpoint spans are exported to by the
+	// OTLP/gRPC exporter, unless explicitly configured otherwise.
+	DefaultOTLPGRPCEndpoint = "localhost:4317"
+
+	// otlpGRPCExportTimeout is the maximum duration of a single export call.
+	otlpGRPCExportTimeout = 10 * time.Second
+)
+
+// otlpGRPCExporter is a SpanExporter sending spans to an OTLP/gRPC endpoint.
+type otlpGRPCExporter struct {
+	serviceName string
+	metadata    metadata.MD
+	callOptions []grpc.CallOption
+
+	endpoint    string
+	dialOptions []grpc.DialOption
+
+	// mu protects the fields below, which are only set once the exporter
+	// connected to the endpoint.
+	mu     sync.Mutex
+	conn   *grpc.ClientConn
+	client coltracepb.TraceServiceClient
+}
+
+// newOTLPGRPCExporter returns a new otlpGRPCExporter for the given options.
+//
+// The connection to the endpoint is established lazily, upon the first
+// export, so that scripts which are only initialized, such as by k6 inspect,
+// never hold one.
+func newOTLPGRPCExporter(opts exporterOptions) (*otlpGRPCExporter, error) {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultOTLPGRPCEndpoint
+	}
+
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		return nil, fmt.Errorf("invalid OTLP/gRPC endpoint %q, expected a host:port address", endpoint)
+	}
+
+	var callOptions []grpc.CallOption
+	switch opts.Compression {
+	case "", CompressionNone:
+	case CompressionGzip:
+		callOptions = append(callOptions, grpc.UseCompressor(gzip.Name))
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", opts.Compression)
+	}
+
+	transportCredentials := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if opts.Insecure {
+		transportCredentials = insecure.NewCredentials()
+	}
+
+	return &otlpGRPCExporter{
+		serviceName: opts.ServiceName,
+		metadata:    metadata.New(opts.Headers),
+		callOptions: callOptions,
+		endpoint:    endpoint,
+		dialOptions: []grpc.DialOption{grpc.WithTransportCredentials(transportCredentials)},
+	}, nil
+}
+
+// traceClient returns the client of the trace service of the configured
+// endpoint, connecting to it unless already done.
+func (e *otlpGRPCExporter) traceClient() (coltracepb.TraceServiceClient, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.client != nil {
+		return e.client, nil
+	}
+
+	conn, err := grpc.Dial(e.endpoint, e.dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to OTLP/gRPC endpoint %q: %w", e.endpoint, err)
+	}
+
+	e.conn = conn
+	e.client = coltracepb.NewTraceServiceClient(conn)
+
+	return e.client, nil
+}
+
+// ExportSpans sends the given spans to the configured OTLP/gRPC endpoint,
+// in a single export call.
+func (e *otlpGRPCExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	request, err := newOTLPTraceRequest(e.serviceName, spans)
+	if err != nil {
+		return err
+	}
+
+	client, err := e.traceClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, otlpGRPCExportTimeout)
+	defer cancel()
+
+	if len(e.metadata) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, e.metadata)
+	}
+
+	response, err := client.Export(ctx, request, e.callOptions...)
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+
+	if partialSuccess := response.GetPartialSuccess(); partialSuccess.GetRejectedSpans() > 0 {
+		return fmt.Errorf(
+			"failed to export spans: %d spans rejected: %s",
+			partialSuccess.GetRejectedSpans(), partialSuccess.GetErrorMessage(),
+		)
+	}
+
+	return nil
+}
+
+// Shutdown closes the connection to the OTLP/gRPC endpoint, if any.
+func (e *otlpGRPCExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn == nil {
+		return nil
+	}
+
+	return e.conn.Close()
+}
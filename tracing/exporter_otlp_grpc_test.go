@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeTraceCollector is an in-process OTLP/gRPC trace collector,
+// recording the export requests it receives.
+type fakeTraceCollector struct {
+	coltracepb.UnimplementedTraceServiceServer
+
+	mu       sync.Mutex
+	requests []*coltracepb.ExportTraceServiceRequest
+	metadata []metadata.MD
+
+	response *coltracepb.ExportTraceServiceResponse
+}
+
+func (c *fakeTraceCollector) Export(
+	ctx context.Context, req *coltracepb.ExportTraceServiceRequest,
+) (*coltracepb.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	c.requests = append(c.requests, req)
+	c.metadata = append(c.metadata, md)
+
+	if c.response != nil {
+		return c.response, nil
+	}
+
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+// startFakeTraceCollector starts a fakeTraceCollector listening on a random
+// local port, and returns it along with its address.
+func startFakeTraceCollector(t *testing.T) (*fakeTraceCollector, string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	collector := &fakeTraceCollector{}
+	server := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(server, collector)
+
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	return collector, listener.Addr().String()
+}
+
+func TestOTLPGRPCExporterExportSpans(t *testing.T) {
+	t.Parallel()
+
+	t.Run("spans should be exported in a single call", func(t *testing.T) {
+		t.Parallel()
+
+		collector, endpoint := startFakeTraceCollector(t)
+		exporter, err := newSpanExporter(exporterOptions{
+			Protocol:    OTLPGRPCExporterProtocol,
+			Endpoint:    endpoint,
+			Headers:     map[string]string{"x-scope-orgid": "tenant"},
+			Insecure:    true,
+			Compression: CompressionGzip,
+			ServiceName: "checkout-test",
+		})
+		require.NoError(t, err)
+
+		now := time.Now()
+		err = exporter.ExportSpans(context.Background(), []*Span{
+			{TraceID: testTraceID, SpanID: testSpanID, Name: "first", StartTime: now, EndTime: now},
+			{TraceID: testTraceID, SpanID: "0102030405060708", Name: "second", StartTime: now, EndTime: now},
+		})
+		require.NoError(t, err)
+
+		collector.mu.Lock()
+		defer collector.mu.Unlock()
+
+		require.Len(t, collector.requests, 1)
+		assert.Equal(t, []string{"tenant"}, collector.metadata[0].Get("x-scope-orgid"))
+
+		resourceSpans := collector.requests[0].ResourceSpans
+		require.Len(t, resourceSpans, 1)
+		assert.Equal(t, "checkout-test", resourceSpans[0].Resource.Attributes[0].Value.GetStringValue())
+		require.Len(t, resourceSpans[0].ScopeSpans, 1)
+		require.Len(t, resourceSpans[0].ScopeSpans[0].Spans, 2)
+		assert.Equal(t, "first", resourceSpans[0].ScopeSpans[0].Spans[0].Name)
+		assert.Equal(t, "second", resourceSpans[0].ScopeSpans[0].Spans[1].Name)
+	})
+
+	t.Run("rejected spans should fail", func(t *testing.T) {
+		t.Parallel()
+
+		collector, endpoint := startFakeTraceCollector(t)
+		collector.response = &coltracepb.ExportTraceServiceResponse{
+			PartialSuccess: &coltracepb.ExportTracePartialSuccess{RejectedSpans: 1, ErrorMessage: "too old"},
+		}
+
+		exporter, err := newSpanExporter(exporterOptions{
+			Protocol: OTLPGRPCExporterProtocol,
+			Endpoint: endpoint,
+			Insecure: true,
+		})
+		require.NoError(t, err)
+
+		err = exporter.ExportSpans(context.Background(), []*Span{{TraceID: testTraceID, SpanID: testSpanID}})
+
+		assert.ErrorContains(t, err, "too old")
+	})
+
+	t.Run("unreachable endpoint should fail", func(t *testing.T) {
+		t.Parallel()
+
+		exporter, err := newSpanExporter(exporterOptions{
+			Protocol: OTLPGRPCExporterProtocol,
+			Endpoint: "127.0.0.1:1",
+			Insecure: true,
+		})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		err = exporter.ExportSpans(ctx, []*Span{{TraceID: testTraceID, SpanID: testSpanID}})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestNewOTLPGRPCExporter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("endpoint without port should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newSpanExporter(exporterOptions{Protocol: OTLPGRPCExporterProtocol, Endpoint: "localhost"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("unsupported compression should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newSpanExporter(exporterOptions{Protocol: OTLPGRPCExporterProtocol, Compression: "zstd"})
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultOTLPHTTPEndpoint is the endpoint spans are exported to by the
+	// OTLP/HTTP exporter, unless explicitly configured otherwise.
+	DefaultOTLPHTTPEndpoint = "http://localhost:4318/v1/traces"
+
+	// otlpHTTPExportTimeout is the maximum duration of a single export request.
+	otlpHTTPExportTimeout = 10 * time.Second
+)
+
+// otlpHTTPExporter is a SpanExporter sending spans to an OTLP/HTTP endpoint,
+// using the binary protobuf encoding.
+type otlpHTTPExporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	gzip        bool
+
+	client *http.Client
+}
+
+// newOTLPHTTPExporter returns a new otlpHTTPExporter for the given options.
+func newOTLPHTTPExporter(opts exporterOptions) (*otlpHTTPExporter, error) {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultOTLPHTTPEndpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP/HTTP endpoint %q, expected a http(s) URL", endpoint)
+	}
+
+	var useGzip bool
+	switch opts.Compression {
+	case "", CompressionNone:
+	case CompressionGzip:
+		useGzip = true
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", opts.Compression)
+	}
+
+	return &otlpHTTPExporter{
+		endpoint:    endpoint,
+		headers:     opts.Headers,
+		serviceName: opts.ServiceName,
+		gzip:        useGzip,
+		client:      &http.Client{Timeout: otlpHTTPExportTimeout},
+	}, nil
+}
+
+// ExportSpans sends the given spans to the configured OTLP/HTTP endpoint.
+func (e *otlpHTTPExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	request, err := newOTLPTraceRequest(e.serviceName, spans)
+	if err != nil {
+		return err
+	}
+
+	body, err := proto.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	if e.gzip {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err = gz.Write(body); err != nil {
+			return fmt.Errorf("failed to compress spans: %w", err)
+		}
+		if err = gz.Close(); err != nil {
+			return fmt.Errorf("failed to compress spans: %w", err)
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if e.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to export spans: unexpected status %d: %s", resp.StatusCode, msg)
+	}
+
+	// Drain the response body, so that the connection can be reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// Shutdown closes the idle connections to the OTLP/HTTP endpoint.
+func (e *otlpHTTPExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
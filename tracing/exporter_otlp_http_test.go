@@ -0,0 +1,132 @@
+package tracing
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOTLPHTTPExporterExportSpans(t *testing.T) {
+	t.Parallel()
+
+	t.Run("spans should be posted as protobuf", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			gotRequest coltracepb.ExportTraceServiceRequest
+			gotHeaders http.Header
+		)
+
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotHeaders = r.Header.Clone()
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.NoError(t, proto.Unmarshal(body, &gotRequest))
+		}))
+		defer collector.Close()
+
+		exporter, err := newSpanExporter(exporterOptions{
+			Endpoint:    collector.URL + "/v1/traces",
+			Headers:     map[string]string{"Authorization": "Bearer token"},
+			ServiceName: "checkout-test",
+		})
+		require.NoError(t, err)
+
+		now := time.Now()
+		err = exporter.ExportSpans(context.Background(), []*Span{
+			{TraceID: testTraceID, SpanID: testSpanID, Name: "HTTP GET", StartTime: now, EndTime: now},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "application/x-protobuf", gotHeaders.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", gotHeaders.Get("Authorization"))
+		require.Len(t, gotRequest.ResourceSpans, 1)
+		resourceSpans := gotRequest.ResourceSpans[0]
+		assert.Equal(t, "checkout-test", resourceSpans.Resource.Attributes[0].Value.GetStringValue())
+		require.Len(t, resourceSpans.ScopeSpans, 1)
+		require.Len(t, resourceSpans.ScopeSpans[0].Spans, 1)
+		assert.Equal(t, "HTTP GET", resourceSpans.ScopeSpans[0].Spans[0].Name)
+	})
+
+	t.Run("non-2xx response should fail", func(t *testing.T) {
+		t.Parallel()
+
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer collector.Close()
+
+		exporter, err := newSpanExporter(exporterOptions{Endpoint: collector.URL})
+		require.NoError(t, err)
+
+		err = exporter.ExportSpans(context.Background(), []*Span{{TraceID: testTraceID, SpanID: testSpanID}})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestNewSpanExporter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("default options should produce an OTLP/HTTP exporter", func(t *testing.T) {
+		t.Parallel()
+
+		exporter, err := newSpanExporter(exporterOptions{})
+
+		require.NoError(t, err)
+		require.IsType(t, &otlpHTTPExporter{}, exporter)
+		assert.Equal(t, DefaultOTLPHTTPEndpoint, exporter.(*otlpHTTPExporter).endpoint)
+		assert.Equal(t, DefaultServiceName, exporter.(*otlpHTTPExporter).serviceName)
+	})
+
+	t.Run("unknown protocol should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newSpanExporter(exporterOptions{Protocol: "carrier-pigeon"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid OTLP/HTTP endpoint should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newSpanExporter(exporterOptions{Endpoint: "localhost:4318"})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestOTLPHTTPExporterCompression(t *testing.T) {
+	t.Parallel()
+
+	var gotRequest coltracepb.ExportTraceServiceRequest
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		require.NoError(t, proto.Unmarshal(body, &gotRequest))
+	}))
+	defer collector.Close()
+
+	exporter, err := newSpanExporter(exporterOptions{Endpoint: collector.URL, Compression: CompressionGzip})
+	require.NoError(t, err)
+
+	err = exporter.ExportSpans(context.Background(), []*Span{{TraceID: testTraceID, SpanID: testSpanID, Name: "span"}})
+
+	require.NoError(t, err)
+	require.Len(t, gotRequest.ResourceSpans, 1)
+	assert.Equal(t, "span", gotRequest.ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+}
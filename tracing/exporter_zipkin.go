@@ -0,0 +1,259 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultZipkinEndpoint is the endpoint spans are exported to by the
+	// Zipkin exporter, unless explicitly configured otherwise.
+	DefaultZipkinEndpoint = "http://localhost:9411/api/v2/spans"
+
+	// zipkinExportTimeout is the maximum duration of a single export request.
+	zipkinExportTimeout = 10 * time.Second
+
+	// zipkinErrorTagName is the tag Zipkin marks failed spans with.
+	zipkinErrorTagName = "error"
+)
+
+// zipkinExporter is a SpanExporter sending spans to a Zipkin collector,
+// using the Zipkin v2 JSON encoding.
+type zipkinExporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+
+	client *http.Client
+}
+
+// newZipkinExporter returns a new zipkinExporter for the given options.
+func newZipkinExporter(opts exporterOptions) (*zipkinExporter, error) {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultZipkinEndpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Zipkin endpoint %q, expected a http(s) URL", endpoint)
+	}
+
+	switch opts.Compression {
+	case "", CompressionNone:
+	default:
+		return nil, fmt.Errorf("unsupported compression for the zipkin protocol: %s", opts.Compression)
+	}
+
+	return &zipkinExporter{
+		endpoint:    endpoint,
+		headers:     opts.Headers,
+		serviceName: opts.ServiceName,
+		client:      &http.Client{Timeout: zipkinExportTimeout},
+	}, nil
+}
+
+// ExportSpans sends the given spans to the configured Zipkin endpoint.
+func (e *zipkinExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	zipkinSpans := make([]zipkinSpan, 0, len(spans))
+	for _, span := range spans {
+		zipkinSpans = append(zipkinSpans, toZipkinSpan(e.serviceName, span))
+	}
+
+	body, err := json.Marshal(zipkinSpans)
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to export spans: unexpected status %d: %s", resp.StatusCode, msg)
+	}
+
+	// Drain the response body, so that the connection can be reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// Shutdown closes the idle connections to the Zipkin endpoint.
+func (e *zipkinExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// zipkinSpan is the Zipkin v2 JSON representation of a span.
+type zipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId,omitempty"`
+	Name           string             `json:"name,omitempty"`
+	Kind           string             `json:"kind,omitempty"`
+	Timestamp      int64              `json:"timestamp,omitempty"`
+	Duration       int64              `json:"duration,omitempty"`
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint,omitempty"`
+	Annotations    []zipkinAnnotation `json:"annotations,omitempty"`
+	Tags           map[string]string  `json:"tags,omitempty"`
+}
+
+// zipkinEndpoint is the Zipkin v2 JSON representation of a network endpoint.
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	IPv4        string `json:"ipv4,omitempty"`
+	IPv6        string `json:"ipv6,omitempty"`
+	Port        int    `json:"port,omitempty"`
+}
+
+// zipkinAnnotation is the Zipkin v2 JSON representation of a span event.
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// toZipkinSpan converts a span to its Zipkin v2 representation, reported
+// under the given local service name.
+//
+// The remote endpoint of the span is derived from its http.url attribute,
+// if any.
+func toZipkinSpan(serviceName string, span *Span) zipkinSpan {
+	zs := zipkinSpan{
+		TraceID:       span.TraceID,
+		ID:            span.SpanID,
+		ParentID:      span.ParentSpanID,
+		Name:          span.Name,
+		Kind:          zipkinSpanKind(span.Kind),
+		LocalEndpoint: &zipkinEndpoint{ServiceName: serviceName},
+	}
+
+	if !span.StartTime.IsZero() {
+		zs.Timestamp = span.StartTime.UnixMicro()
+
+		// Zipkin expects a duration of at least a microsecond when set.
+		zs.Duration = span.EndTime.Sub(span.StartTime).Microseconds()
+		if zs.Duration < 1 {
+			zs.Duration = 1
+		}
+	}
+
+	if rawURL, ok := span.Attributes[httpURLAttributeKey].(string); ok {
+		zs.RemoteEndpoint = zipkinEndpointFromURL(rawURL)
+	}
+
+	for _, event := range span.Events {
+		zs.Annotations = append(zs.Annotations, zipkinAnnotation{
+			Timestamp: event.Time.UnixMicro(),
+			Value:     event.Name,
+		})
+	}
+
+	if len(span.Attributes) > 0 || span.Status.Code == SpanStatusError {
+		zs.Tags = make(map[string]string, len(span.Attributes)+1)
+	}
+
+	for key, value := range span.Attributes {
+		zs.Tags[key] = zipkinTagValue(value)
+	}
+
+	if span.Status.Code == SpanStatusError {
+		zs.Tags[zipkinErrorTagName] = span.Status.Message
+	}
+
+	return zs
+}
+
+// zipkinSpanKind returns the Zipkin representation of a span kind.
+//
+// Zipkin has no equivalent of the internal and unspecified kinds, which
+// are left empty.
+func zipkinSpanKind(kind SpanKind) string {
+	switch kind {
+	case SpanKindServer:
+		return "SERVER"
+	case SpanKindClient:
+		return "CLIENT"
+	case SpanKindProducer:
+		return "PRODUCER"
+	case SpanKindConsumer:
+		return "CONSUMER"
+	default:
+		return ""
+	}
+}
+
+// zipkinEndpointFromURL returns the Zipkin endpoint a request to the given
+// URL was sent to, or nil if the URL can't be parsed.
+//
+// Hosts given as IP addresses are reported as such, while host names are
+// reported as the remote service name. When the URL holds no explicit port,
+// the default port of its scheme is used.
+func zipkinEndpointFromURL(rawURL string) *zipkinEndpoint {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return nil
+	}
+
+	endpoint := &zipkinEndpoint{}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip == nil {
+		endpoint.ServiceName = strings.ToLower(host)
+	} else if ip.To4() != nil {
+		endpoint.IPv4 = ip.String()
+	} else {
+		endpoint.IPv6 = ip.String()
+	}
+
+	switch port := u.Port(); {
+	case port != "":
+		endpoint.Port, _ = strconv.Atoi(port)
+	case u.Scheme == "http" || u.Scheme == "ws":
+		endpoint.Port = 80
+	case u.Scheme == "https" || u.Scheme == "wss":
+		endpoint.Port = 443
+	}
+
+	return endpoint
+}
+
+// zipkinTagValue returns the string representation of an attribute value,
+// as Zipkin tags only hold strings.
+//
+// Strings are used as-is, while other values are JSON encoded.
+func zipkinTagValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(encoded)
+}
@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZipkinExporterExportSpans(t *testing.T) {
+	t.Parallel()
+
+	t.Run("spans should be posted as Zipkin v2 JSON", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			gotSpans   []map[string]interface{}
+			gotPath    string
+			gotHeaders http.Header
+		)
+
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotPath = r.URL.Path
+			gotHeaders = r.Header.Clone()
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&gotSpans))
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer collector.Close()
+
+		exporter, err := newSpanExporter(exporterOptions{
+			Protocol: ZipkinExporterProtocol,
+			Endpoint: collector.URL + "/api/v2/spans",
+			Headers:  map[string]string{"Authorization": "Bearer token"},
+		})
+		require.NoError(t, err)
+
+		start := time.UnixMicro(1_600_000_000_000_000)
+		err = exporter.ExportSpans(context.Background(), []*Span{{
+			TraceID:    testTraceID,
+			SpanID:     testSpanID,
+			Name:       "HTTP GET",
+			Kind:       SpanKindClient,
+			StartTime:  start,
+			EndTime:    start.Add(1500 * time.Microsecond),
+			Attributes: map[string]interface{}{httpURLAttributeKey: "https://test.k6.io/", httpStatusCodeAttributeKey: 500},
+			Status:     SpanStatus{Code: SpanStatusError, Message: "Internal Server Error"},
+		}})
+
+		require.NoError(t, err)
+		assert.Equal(t, "/api/v2/spans", gotPath)
+		assert.Equal(t, "application/json", gotHeaders.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", gotHeaders.Get("Authorization"))
+		require.Len(t, gotSpans, 1)
+		assert.Equal(t, map[string]interface{}{
+			"traceId":        testTraceID,
+			"id":             testSpanID,
+			"name":           "HTTP GET",
+			"kind":           "CLIENT",
+			"timestamp":      float64(1_600_000_000_000_000),
+			"duration":       float64(1500),
+			"localEndpoint":  map[string]interface{}{"serviceName": DefaultServiceName},
+			"remoteEndpoint": map[string]interface{}{"serviceName": "test.k6.io", "port": float64(443)},
+			"tags": map[string]interface{}{
+				httpURLAttributeKey:        "https://test.k6.io/",
+				httpStatusCodeAttributeKey: "500",
+				zipkinErrorTagName:         "Internal Server Error",
+			},
+		}, gotSpans[0])
+	})
+
+	t.Run("non-2xx response should fail", func(t *testing.T) {
+		t.Parallel()
+
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer collector.Close()
+
+		exporter, err := newSpanExporter(exporterOptions{Protocol: ZipkinExporterProtocol, Endpoint: collector.URL})
+		require.NoError(t, err)
+
+		err = exporter.ExportSpans(context.Background(), []*Span{{TraceID: testTraceID, SpanID: testSpanID}})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestZipkinEndpointFromURL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		url  string
+		want *zipkinEndpoint
+	}{
+		{name: "host name with default http port", url: "http://Example.com/path", want: &zipkinEndpoint{ServiceName: "example.com", Port: 80}},
+		{name: "host name with explicit port", url: "https://example.com:8443", want: &zipkinEndpoint{ServiceName: "example.com", Port: 8443}},
+		{name: "ipv4 host", url: "http://127.0.0.1:8080", want: &zipkinEndpoint{IPv4: "127.0.0.1", Port: 8080}},
+		{name: "ipv6 host", url: "https://[::1]/", want: &zipkinEndpoint{IPv6: "::1", Port: 443}},
+		{name: "invalid url", url: "::not a url", want: nil},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.want, zipkinEndpointFromURL(tc.url))
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package tracing
 
-import "github.com/dop251/goja"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dop251/goja"
+)
 
 // isInstanceOf returns true if the given value is an instance of one of the
 // given types. The types are specified as strings, which are the names of the
@@ -22,3 +27,46 @@ func isInstanceOf(rt *goja.Runtime, v goja.Value, instanceOf ...string) bool {
 func isNullish(value goja.Value) bool {
 	return value == nil || goja.IsUndefined(value) || goja.IsNull(value)
 }
+
+// copyObject returns a shallow copy of the given object's own properties.
+// If the given value is nullish, an empty object is returned.
+func copyObject(rt *goja.Runtime, v goja.Value) *goja.Object {
+	copied := rt.NewObject()
+	if isNullish(v) {
+		return copied
+	}
+
+	obj := v.ToObject(rt)
+	for _, key := range obj.Keys() {
+		// Setting a property on a freshly created object cannot fail.
+		_ = copied.Set(key, obj.Get(key))
+	}
+
+	return copied
+}
+
+// requireModule returns the exports of the given k6 module, as resolved by
+// the runtime's require function.
+//
+// Unlike running a require statement, calling the require function doesn't
+// run a nested program, and is thus safe from within constructors.
+func requireModule(rt *goja.Runtime, name string) (*goja.Object, error) {
+	requireFn, ok := goja.AssertFunction(rt.Get("require"))
+	if !ok {
+		return nil, fmt.Errorf("failed to require %s: require is not a function", name)
+	}
+
+	module, err := requireFn(goja.Undefined(), rt.ToValue(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return module.ToObject(rt), nil
+}
+
+// isUnknownModuleError returns true if the given error, produced by
+// requiring the given module, reports that the running k6 version
+// doesn't ship it.
+func isUnknownModuleError(err error, name string) bool {
+	return strings.Contains(err.Error(), "unknown module: "+name)
+}
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// k6GroupFunctionName is the name of the k6 module's group function.
+const k6GroupFunctionName = "group"
+
+// k6GroupAttributeKey is the attribute key holding the path of the
+// k6 group a group span covers.
+const k6GroupAttributeKey = "k6.group"
+
+// InstrumentGroups instruments the k6 module's group function, so that
+// each group() call produces a span covering it.
+//
+// Group spans are active for the duration of their group: the requests,
+// spans and nested groups of a group are nested beneath its span. As
+// the group span covers the group's callback, its duration matches the
+// group's group_duration metric.
+func (t *Tracing) InstrumentGroups() {
+	rt := t.vu.Runtime()
+
+	// Explicitly inject the k6 module in the VU's runtime, in order to
+	// override its group function in place.
+	k6ModuleValue, err := rt.RunString(`require('k6')`)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	k6ModuleObj := k6ModuleValue.ToObject(rt)
+
+	originalGroupFn, ok := goja.AssertFunction(k6ModuleObj.Get(k6GroupFunctionName))
+	if !ok {
+		common.Throw(rt, fmt.Errorf("%s is not a function", k6GroupFunctionName))
+	}
+
+	if err = k6ModuleObj.Set(k6GroupFunctionName, t.instrumentGroup(originalGroupFn)); err != nil {
+		common.Throw(rt, err)
+	}
+}
+
+// instrumentGroup returns a new function that wraps the original k6 group
+// function, running the group's callback within a group span.
+//
+// The callback is wrapped, rather than the group call itself, so that the
+// span starts and ends along with the group_duration measurement, and sees
+// the VU's state updated with the group.
+func (t *Tracing) instrumentGroup(groupFn goja.Callable) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		rt := t.vu.Runtime()
+
+		name := call.Argument(0)
+		fn, ok := goja.AssertFunction(call.Argument(1))
+		if !ok {
+			// Let the original function report its misuse.
+			result, err := groupFn(call.This, call.Arguments...)
+			if err != nil {
+				common.Throw(rt, err)
+			}
+
+			return result
+		}
+
+		tracedFn := func(inner goja.FunctionCall) goja.Value {
+			opts := &spanOptions{Attributes: make(map[string]interface{})}
+			if state := t.vu.State(); state != nil && state.Group != nil {
+				opts.Attributes[k6GroupAttributeKey] = state.Group.Path
+			}
+
+			result, err := t.runInSpan(name.String(), opts, func(*scriptSpan) (goja.Value, error) {
+				return fn(inner.This, inner.Arguments...)
+			})
+			if err != nil {
+				common.Throw(rt, err)
+			}
+
+			return result
+		}
+
+		result, err := groupFn(call.This, name, rt.ToValue(tracedFn))
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		return result
+	}
+}
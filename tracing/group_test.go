@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/js/modules/k6"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestTracingInstrumentGroups(t *testing.T) {
+	t.Parallel()
+
+	// newTestTracing returns a Tracing instance whose k6 module's group
+	// function is instrumented, running in the VU context, along with
+	// the channel the VU's samples are pushed to.
+	newTestTracing := func(t *testing.T) (*Tracing, *goja.Runtime, chan metrics.SampleContainer) {
+		t.Helper()
+
+		testSetup := modulestest.NewRuntime(t)
+		rt := testSetup.VU.Runtime()
+		rt.SetFieldNameMapper(goja.TagFieldNameMapper("js", true))
+
+		// As k6 does, the require function returns the same module
+		// object on every call.
+		k6Module := rt.NewObject()
+		for name, export := range k6.New().NewModuleInstance(testSetup.VU).Exports().Named {
+			require.NoError(t, k6Module.Set(name, export))
+		}
+		require.NoError(t, rt.Set("require", func(string) *goja.Object { return k6Module }))
+
+		tracing := &Tracing{vu: testSetup.VU, propagator: &W3CPropagator{}}
+		tracing.InstrumentGroups()
+
+		root, err := lib.NewGroup("", nil)
+		require.NoError(t, err)
+
+		registry := testSetup.VU.InitEnv().Registry
+		samples := make(chan metrics.SampleContainer, 100)
+		testSetup.MoveToVUContext(&lib.State{
+			Group:          root,
+			Samples:        samples,
+			Tags:           lib.NewVUStateTags(registry.RootTagSet()),
+			BuiltinMetrics: testSetup.BuiltinMetrics,
+			Options:        lib.Options{SystemTags: &metrics.DefaultSystemTagSet},
+		})
+
+		_, err = rt.RunString(`const { group } = require('k6')`)
+		require.NoError(t, err)
+
+		return tracing, rt, samples
+	}
+
+	t.Run("groups should run within a span", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, rt, samples := newTestTracing(t)
+		var spans []*scriptSpan
+		require.NoError(t, rt.Set("capture", func() { spans = append(spans, tracing.activeSpan()) }))
+
+		got, err := rt.RunString(`
+			group("checkout", () => {
+				capture();
+				group("payment", () => capture());
+				return 42;
+			})
+		`)
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), got.Export())
+		require.Len(t, spans, 2)
+
+		checkout, payment := spans[0], spans[1]
+		assert.Equal(t, "checkout", checkout.span.Name)
+		assert.Equal(t, "::checkout", checkout.span.Attributes[k6GroupAttributeKey])
+		assert.Equal(t, "payment", payment.span.Name)
+		assert.Equal(t, "::checkout::payment", payment.span.Attributes[k6GroupAttributeKey])
+		assert.Equal(t, checkout.TraceID, payment.TraceID)
+		assert.Equal(t, checkout.SpanID, payment.span.ParentSpanID)
+		assert.True(t, checkout.ended)
+		assert.True(t, payment.ended)
+		assert.Nil(t, tracing.activeSpan())
+
+		// The group span should cover the same period as the group's
+		// group_duration metric.
+		var groupDurations []metrics.Sample
+		for _, container := range metrics.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				if sample.Metric.Name == metrics.GroupDurationName {
+					groupDurations = append(groupDurations, sample)
+				}
+			}
+		}
+		require.Len(t, groupDurations, 2)
+		spanDuration := metrics.D(checkout.span.EndTime.Sub(checkout.span.StartTime))
+		assert.InDelta(t, groupDurations[1].Value, spanDuration, 1)
+	})
+
+	t.Run("a group throwing an exception should fail its span", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, rt, _ := newTestTracing(t)
+		var span *scriptSpan
+		require.NoError(t, rt.Set("capture", func() { span = tracing.activeSpan() }))
+
+		_, err := rt.RunString(`group("checkout", () => { capture(); throw new Error("out of stock") })`)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "out of stock")
+		require.NotNil(t, span)
+		assert.Equal(t, SpanStatusError, span.span.Status.Code)
+		assert.Nil(t, tracing.activeSpan())
+	})
+}
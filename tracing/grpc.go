@@ -0,0 +1,245 @@
+package tracing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/netext/grpcext"
+	"go.k6.io/k6/metrics"
+	"google.golang.org/grpc/codes"
+)
+
+// k6 gRPC module names.
+const (
+	k6GRPCClientConstructorName = "Client"
+	k6GRPCStreamConstructorName = "Stream"
+	k6GRPCInvokeMethodName      = "invoke"
+	k6GRPCMetadataParamName     = "metadata"
+)
+
+// gRPC client spans attribute keys, as defined by the OpenTelemetry
+// semantic conventions.
+const (
+	rpcSystemAttributeKey         = "rpc.system"
+	rpcServiceAttributeKey        = "rpc.service"
+	rpcMethodAttributeKey         = "rpc.method"
+	rpcGRPCStatusCodeAttributeKey = "rpc.grpc.status_code"
+
+	rpcSystemGRPC = "grpc"
+)
+
+// InstrumentGRPC instruments the k6/net/grpc module with tracing metadata.
+//
+// When options are given, the gRPC calls are traced with their own
+// configuration, leaving the one set by InstrumentHTTP untouched.
+// Otherwise, the configuration set by a previous InstrumentHTTP call
+// is used.
+//
+// The clients created by the module's Client constructor after this call
+// inject the trace context headers produced by the configured propagator
+// as gRPC metadata of their invoke calls, and record their client spans.
+//
+// Only unary calls are supported. The k6 v0.42 gRPC module doesn't support
+// streams, and the streams of the k6 versions which do aren't instrumented:
+// they carry no trace context, and a warning is logged when the module
+// exposes them.
+func (t *Tracing) InstrumentGRPC(options *instrumentationOptions) {
+	rt := t.vu.Runtime()
+
+	tracing, err := t.instrumentationTracing("instrumentGRPC", options)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	// Explicitly inject the grpc module in the VU's runtime, in order to
+	// override its constructors in place.
+	grpcModuleValue, err := rt.RunString(`require('k6/net/grpc')`)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	grpcModuleObj := grpcModuleValue.ToObject(rt)
+
+	clientConstructor := grpcModuleObj.Get(k6GRPCClientConstructorName)
+	if _, ok := goja.AssertConstructor(clientConstructor); !ok {
+		common.Throw(rt, fmt.Errorf("grpc.%s is not a constructor", k6GRPCClientConstructorName))
+	}
+
+	if err = grpcModuleObj.Set(k6GRPCClientConstructorName, tracing.instrumentGRPCClient(clientConstructor)); err != nil {
+		common.Throw(rt, err)
+	}
+
+	if !isNullish(grpcModuleObj.Get(k6GRPCStreamConstructorName)) {
+		tracing.logger().Warnf(
+			"gRPC streams aren't instrumented, and carry no trace context; only the %s calls of clients are",
+			k6GRPCInvokeMethodName,
+		)
+	}
+}
+
+// instrumentGRPCClient returns a new constructor that wraps the original
+// grpc.Client constructor, producing clients whose invoke method is
+// instrumented.
+//
+// As the clients produced by k6 are backed by Go values, which can't be
+// modified, the produced clients inherit from them, and only override
+// their invoke method.
+func (t *Tracing) instrumentGRPCClient(clientConstructor goja.Value) func(call goja.ConstructorCall) *goja.Object {
+	return func(call goja.ConstructorCall) *goja.Object {
+		rt := t.vu.Runtime()
+
+		client, err := rt.New(clientConstructor, call.Arguments...)
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		invokeFn, ok := goja.AssertFunction(client.Get(k6GRPCInvokeMethodName))
+		if !ok {
+			common.Throw(rt, fmt.Errorf("grpc.Client.%s is not a function", k6GRPCInvokeMethodName))
+		}
+
+		tracedClient := rt.NewObject()
+		if err = tracedClient.SetPrototype(client); err != nil {
+			common.Throw(rt, err)
+		}
+
+		if err = tracedClient.Set(k6GRPCInvokeMethodName, t.instrumentGRPCInvoke(client, invokeFn)); err != nil {
+			common.Throw(rt, err)
+		}
+
+		return tracedClient
+	}
+}
+
+// instrumentGRPCInvoke returns a new function that wraps the original
+// grpc.Client.invoke method, adding tracing metadata to the call.
+//
+// The trace and span IDs are attached to the VU's metadata for the duration
+// of the call, so that they're attached to the metrics the call emits.
+func (t *Tracing) instrumentGRPCInvoke(client goja.Value, invokeFn goja.Callable) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		rt := t.vu.Runtime()
+
+		method := call.Argument(0)
+
+		params, trace, err := t.tracedGRPCParams(call.Argument(2))
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		vuState := t.vu.State()
+		if vuState != nil {
+			vuState.Tags.Modify(func(t *metrics.TagsAndMeta) {
+				t.SetMetadata(metadataTraceIDKeyName, trace.TraceID)
+				t.SetMetadata(metadataSpanIDKeyName, trace.SpanID)
+			})
+		}
+
+		result, err := invokeFn(client, method, call.Argument(1), params)
+
+		if vuState != nil {
+			vuState.Tags.Modify(func(t *metrics.TagsAndMeta) {
+				t.DeleteMetadata(metadataTraceIDKeyName)
+				t.DeleteMetadata(metadataSpanIDKeyName)
+			})
+		}
+
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		t.recordGRPCSpan(trace, method.String(), result, time.Now())
+
+		return result
+	}
+}
+
+// tracedGRPCParams returns a copy of the given gRPC params, holding the
+// trace context headers of a new request trace as metadata.
+//
+// The given params, and their metadata object, are left untouched, so
+// that they can safely be reused by the script.
+func (t *Tracing) tracedGRPCParams(params goja.Value) (*goja.Object, *requestTrace, error) {
+	rt := t.vu.Runtime()
+
+	trace, header, err := t.newRequestTrace()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tracedParams := copyObject(rt, params)
+
+	metadata := copyObject(rt, tracedParams.Get(k6GRPCMetadataParamName))
+	for key, values := range header {
+		// gRPC metadata keys are lowercase, and k6 only accepts
+		// string metadata values.
+		if err = metadata.Set(strings.ToLower(key), strings.Join(values, ",")); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err = tracedParams.Set(k6GRPCMetadataParamName, metadata); err != nil {
+		return nil, nil, err
+	}
+
+	trace.startTime = time.Now()
+
+	return tracedParams, trace, nil
+}
+
+// recordGRPCSpan records the client span of an instrumented gRPC call,
+// from the k6 response it produced.
+//
+// Spans are only recorded for sampled calls, and when an exporter is
+// configured.
+func (t *Tracing) recordGRPCSpan(trace *requestTrace, method string, response goja.Value, endTime time.Time) {
+	if t.processor == nil || !trace.IsSampled() || isNullish(response) {
+		return
+	}
+
+	res, ok := response.Export().(*grpcext.Response)
+	if !ok {
+		return
+	}
+
+	t.exportSpans(newGRPCClientSpan(trace, method, res, endTime))
+}
+
+// newGRPCClientSpan returns a client span describing the gRPC call a k6
+// response was received for.
+//
+// As per the OpenTelemetry semantic conventions, the span is named after
+// the full method name, without its leading slash.
+func newGRPCClientSpan(trace *requestTrace, method string, response *grpcext.Response, endTime time.Time) *Span {
+	method = strings.TrimPrefix(method, "/")
+
+	attributes := map[string]interface{}{
+		rpcSystemAttributeKey:         rpcSystemGRPC,
+		rpcGRPCStatusCodeAttributeKey: int64(response.Status),
+	}
+
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		attributes[rpcServiceAttributeKey] = method[:i]
+		attributes[rpcMethodAttributeKey] = method[i+1:]
+	}
+
+	span := &Span{
+		TraceID:      trace.TraceID,
+		SpanID:       trace.SpanID,
+		ParentSpanID: trace.ParentSpanID,
+		Name:         method,
+		Kind:         SpanKindClient,
+		StartTime:    trace.startTime,
+		EndTime:      endTime,
+		Attributes:   attributes,
+	}
+
+	if response.Status != codes.OK {
+		span.Status = SpanStatus{Code: SpanStatusError, Message: response.Status.String()}
+	}
+
+	return span
+}
@@ -0,0 +1,198 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k6grpc "go.k6.io/k6/js/modules/k6/grpc"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib/netext/grpcext"
+	"go.k6.io/k6/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+)
+
+func TestTracingTracedGRPCParams(t *testing.T) {
+	t.Parallel()
+
+	t.Run("trace context headers should be set as lowercase metadata", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU, propagator: &JaegerPropagator{}}
+
+		params, trace, err := tracing.tracedGRPCParams(nil)
+
+		require.NoError(t, err)
+		metadata := params.Get(k6GRPCMetadataParamName).ToObject(testSetup.VU.Runtime())
+		assert.Equal(t, trace.TraceID+":"+trace.SpanID+":0:1", metadata.Get("uber-trace-id").String())
+		assert.False(t, trace.startTime.IsZero())
+	})
+
+	t.Run("given params and metadata should be left untouched", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		rt := testSetup.VU.Runtime()
+		tracing := &Tracing{vu: testSetup.VU, propagator: &W3CPropagator{}}
+
+		given, err := rt.RunString(`({timeout: "10s", metadata: {"x-user": "bob"}})`)
+		require.NoError(t, err)
+
+		params, _, err := tracing.tracedGRPCParams(given)
+
+		require.NoError(t, err)
+		assert.Equal(t, "10s", params.Get("timeout").String())
+		metadata := params.Get(k6GRPCMetadataParamName).ToObject(rt)
+		assert.Equal(t, "bob", metadata.Get("x-user").String())
+		assert.NotNil(t, metadata.Get("traceparent"))
+
+		givenMetadata := given.ToObject(rt).Get(k6GRPCMetadataParamName).ToObject(rt)
+		assert.Equal(t, []string{"x-user"}, givenMetadata.Keys())
+	})
+}
+
+func TestNewGRPCClientSpan(t *testing.T) {
+	t.Parallel()
+
+	trace := &requestTrace{
+		SpanContext: SpanContext{
+			TraceID:      "0af7651916cd43dd8448eb211c80319c",
+			SpanID:       "b7ad6b7169203331",
+			ParentSpanID: "00f067aa0ba902b7",
+		},
+		startTime: time.Unix(0, 0),
+	}
+	endTime := time.Unix(1, 0)
+
+	t.Run("a successful call should produce a client span", func(t *testing.T) {
+		t.Parallel()
+
+		span := newGRPCClientSpan(trace, "/main.RouteGuide/GetFeature", &grpcext.Response{Status: codes.OK}, endTime)
+
+		assert.Equal(t, "main.RouteGuide/GetFeature", span.Name)
+		assert.Equal(t, SpanKindClient, span.Kind)
+		assert.Equal(t, trace.TraceID, span.TraceID)
+		assert.Equal(t, trace.SpanID, span.SpanID)
+		assert.Equal(t, trace.ParentSpanID, span.ParentSpanID)
+		assert.Equal(t, endTime, span.EndTime)
+		assert.Equal(t, map[string]interface{}{
+			rpcSystemAttributeKey:         rpcSystemGRPC,
+			rpcServiceAttributeKey:        "main.RouteGuide",
+			rpcMethodAttributeKey:         "GetFeature",
+			rpcGRPCStatusCodeAttributeKey: int64(codes.OK),
+		}, span.Attributes)
+		assert.Equal(t, SpanStatusUnset, span.Status.Code)
+	})
+
+	t.Run("a failed call should set the span status to error", func(t *testing.T) {
+		t.Parallel()
+
+		span := newGRPCClientSpan(trace, "main.RouteGuide/GetFeature", &grpcext.Response{Status: codes.NotFound}, endTime)
+
+		assert.Equal(t, "main.RouteGuide/GetFeature", span.Name)
+		assert.Equal(t, int64(codes.NotFound), span.Attributes[rpcGRPCStatusCodeAttributeKey])
+		assert.Equal(t, SpanStatus{Code: SpanStatusError, Message: codes.NotFound.String()}, span.Status)
+	})
+}
+
+func TestTracingInstrumentGRPC(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing configuration should throw", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU}
+
+		assert.Panics(t, func() { tracing.InstrumentGRPC(nil) })
+	})
+
+	t.Run("invoke should carry the trace context of its call", func(t *testing.T) {
+		t.Parallel()
+
+		metadatas := make(chan metadata.MD, 1)
+		srv := grpc.NewServer(grpc.UnaryInterceptor(func(
+			ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+		) (interface{}, error) {
+			if md, ok := metadata.FromIncomingContext(ctx); ok {
+				metadatas <- md
+			}
+
+			return handler(ctx, req)
+		}))
+		healthpb.RegisterHealthServer(srv, health.NewServer())
+		reflection.Register(srv)
+		t.Cleanup(srv.Stop)
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go srv.Serve(lis) //nolint:errcheck
+
+		testSetup := modulestest.NewRuntime(t)
+		rt := testSetup.VU.Runtime()
+		require.NoError(t, rt.Set("addr", lis.Addr().String()))
+
+		mi, ok := New().NewModuleInstance(testSetup.VU).(*ModuleInstance)
+		require.True(t, ok)
+
+		grpcModule := rt.NewObject()
+		for name, value := range k6grpc.New().NewModuleInstance(testSetup.VU).Exports().Named {
+			require.NoError(t, grpcModule.Set(name, value))
+		}
+
+		require.NoError(t, rt.Set("require", func(string) goja.Value { return grpcModule }))
+		require.NoError(t, rt.Set("instrumentGRPC", mi.Exports().Named["instrumentGRPC"]))
+
+		_, err = rt.RunString(`
+			instrumentGRPC({propagator: "w3c"});
+			const client = new (require("k6/net/grpc").Client)();
+		`)
+		require.NoError(t, err)
+
+		moveToTestVUContext(t, testSetup)
+		testSetup.VU.StateField.Dialer = &net.Dialer{}
+		samples := make(chan metrics.SampleContainer, 1000)
+		testSetup.VU.StateField.Samples = samples
+
+		status, err := rt.RunString(`
+			client.connect(addr, {plaintext: true, reflect: true});
+			client.invoke("grpc.health.v1.Health/Check", {}, {metadata: {"x-user": "bob"}}).status;
+		`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(codes.OK), status.ToInteger())
+
+		md := <-metadatas
+		assert.Equal(t, []string{"bob"}, md.Get("x-user"))
+		require.Len(t, md.Get(W3CHeaderName), 1)
+		traceparent := strings.Split(md.Get(W3CHeaderName)[0], "-")
+		require.Len(t, traceparent, 4)
+
+		var found bool
+		for len(samples) > 0 {
+			for _, sample := range (<-samples).GetSamples() {
+				if sample.Metric.Name != metrics.GRPCReqDurationName {
+					continue
+				}
+
+				found = true
+				assert.Equal(t, traceparent[1], sample.Metadata[metadataTraceIDKeyName])
+				assert.Equal(t, traceparent[2], sample.Metadata[metadataSpanIDKeyName])
+			}
+		}
+		assert.True(t, found, "the call should emit a %s sample", metrics.GRPCReqDurationName)
+
+		// The trace metadata doesn't leak into the VU's other metrics.
+		assert.NotContains(t, testSetup.VU.State().Tags.GetCurrentValues().Metadata, metadataTraceIDKeyName)
+	})
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"time"
+
+	"go.k6.io/k6/lib/netext/httpext"
+)
+
+// HTTP client spans attribute keys.
+const (
+	httpMethodAttributeKey     = "http.method"
+	httpURLAttributeKey        = "http.url"
+	httpStatusCodeAttributeKey = "http.status_code"
+
+	k6TimingsBlockedAttributeKey        = "k6.http.timings.blocked"
+	k6TimingsLookingUpAttributeKey      = "k6.http.timings.looking_up"
+	k6TimingsConnectingAttributeKey     = "k6.http.timings.connecting"
+	k6TimingsTLSHandshakingAttributeKey = "k6.http.timings.tls_handshaking"
+	k6TimingsSendingAttributeKey        = "k6.http.timings.sending"
+	k6TimingsWaitingAttributeKey        = "k6.http.timings.waiting"
+	k6TimingsReceivingAttributeKey      = "k6.http.timings.receiving"
+)
+
+// newHTTPClientSpan returns a client span describing the HTTP request a k6
+// response was received for.
+//
+// The span is identified by the trace and span IDs propagated with the
+// request, and its attributes hold the k6 timings breakdown of the request,
+// expressed in milliseconds.
+func newHTTPClientSpan(trace *requestTrace, response *httpext.Response, endTime time.Time) *Span {
+	var method string
+	if response.Request != nil {
+		method = response.Request.Method
+	}
+
+	span := &Span{
+		TraceID:      trace.TraceID,
+		SpanID:       trace.SpanID,
+		ParentSpanID: trace.ParentSpanID,
+		Name:         "HTTP " + method,
+		Kind:         SpanKindClient,
+		StartTime:    trace.startTime,
+		EndTime:      endTime,
+		Attributes: map[string]interface{}{
+			httpMethodAttributeKey:              method,
+			httpURLAttributeKey:                 response.URL,
+			httpStatusCodeAttributeKey:          response.Status,
+			k6TimingsBlockedAttributeKey:        response.Timings.Blocked,
+			k6TimingsLookingUpAttributeKey:      response.Timings.LookingUp,
+			k6TimingsConnectingAttributeKey:     response.Timings.Connecting,
+			k6TimingsTLSHandshakingAttributeKey: response.Timings.TLSHandshaking,
+			k6TimingsSendingAttributeKey:        response.Timings.Sending,
+			k6TimingsWaitingAttributeKey:        response.Timings.Waiting,
+			k6TimingsReceivingAttributeKey:      response.Timings.Receiving,
+		},
+	}
+
+	// As per the OpenTelemetry semantic conventions, client spans of
+	// requests answered with a 4xx or 5xx status code are errors.
+	switch {
+	case response.Error != "":
+		span.Status = SpanStatus{Code: SpanStatusError, Message: response.Error}
+	case response.Status >= 400:
+		span.Status = SpanStatus{Code: SpanStatusError}
+	}
+
+	return span
+}
+
+// httpTimingsEndTime returns the time at which a request started at the
+// given time ended, according to its k6 timings breakdown.
+func httpTimingsEndTime(startTime time.Time, timings httpext.ResponseTimings) time.Time {
+	total := timings.Blocked + timings.LookingUp + timings.Connecting + timings.TLSHandshaking +
+		timings.Sending + timings.Waiting + timings.Receiving
+
+	return startTime.Add(time.Duration(total * float64(time.Millisecond)))
+}
@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.k6.io/k6/lib"
+)
+
+// Trace scopes, defining how many requests share the same trace.
+const (
+	// TraceScopeRequest produces a distinct trace per request.
+	TraceScopeRequest = "request"
+
+	// TraceScopeIteration produces a distinct trace per VU iteration,
+	// shared by all the requests of the iteration.
+	TraceScopeIteration = "iteration"
+)
+
+// iterationSpanName is the name of the root span of iteration traces.
+const iterationSpanName = "iteration"
+
+// Iteration spans attribute keys.
+const (
+	k6ScenarioAttributeKey  = "k6.scenario"
+	k6VUAttributeKey        = "k6.vu"
+	k6IterationAttributeKey = "k6.iteration"
+)
+
+// iterationTrace holds the trace shared by the requests
+// of a single VU iteration.
+type iterationTrace struct {
+	// ctx is the context of the iteration.
+	ctx context.Context
+
+	// span is the root span of the iteration's trace.
+	span *Span
+
+	// sampled indicates whether the iteration's trace is sampled.
+	sampled bool
+}
+
+// parseTraceScope validates the given trace scope name, defaulting
+// to the request scope.
+func parseTraceScope(scope string) (string, error) {
+	switch scope {
+	case "", TraceScopeRequest:
+		return TraceScopeRequest, nil
+	case TraceScopeIteration:
+		return TraceScopeIteration, nil
+	default:
+		return "", fmt.Errorf("unknown trace scope: %s", scope)
+	}
+}
+
+// currentIterationTrace returns the trace of the VU's current iteration,
+// starting it if the iteration didn't produce one yet.
+//
+// It returns nil outside of the VU context, where there's no iteration.
+func (t *Tracing) currentIterationTrace() (*iterationTrace, error) {
+	state, ctx := t.vu.State(), t.vu.Context()
+	if state == nil || ctx == nil {
+		return nil, nil //nolint:nilnil
+	}
+
+	// The iteration trace is shared by the module instance and
+	// the tracing clients created from it.
+	module := t.module()
+
+	// Each VU iteration runs with its own context.
+	if module.iteration != nil && module.iteration.ctx == ctx {
+		return module.iteration, nil
+	}
+
+	traceID, err := t.newTraceID()
+	if err != nil {
+		return nil, err
+	}
+
+	spanID, err := NewSpanID()
+	if err != nil {
+		return nil, err
+	}
+
+	var scenario string
+	if scenarioState := lib.GetScenarioState(ctx); scenarioState != nil {
+		scenario = scenarioState.Name
+	}
+
+	iteration := &iterationTrace{
+		ctx: ctx,
+		span: &Span{
+			TraceID:   traceID,
+			SpanID:    spanID,
+			Name:      iterationSpanName,
+			Kind:      SpanKindInternal,
+			StartTime: time.Now(),
+			Attributes: map[string]interface{}{
+				k6ScenarioAttributeKey:  scenario,
+				k6VUAttributeKey:        int64(state.VUID),
+				k6IterationAttributeKey: state.Iteration,
+			},
+		},
+		sampled: t.shouldSample(),
+	}
+
+	// The iteration span ends with the iteration, at which point the
+	// VU may already run its next iteration, and thus is handed to the
+	// processor directly, rather than through the VU.
+	if t.processor != nil && iteration.sampled {
+		t.root.watchTestRun(t.vu, t.logger())
+
+		processor := t.processor
+		processor.watchIteration(ctx, func() {
+			iteration.span.EndTime = time.Now()
+			processor.enqueue(iteration.span)
+		})
+	}
+
+	module.iteration = iteration
+
+	return iteration, nil
+}
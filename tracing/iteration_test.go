@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestParseTraceScope(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]string{
+		"":                  TraceScopeRequest,
+		TraceScopeRequest:   TraceScopeRequest,
+		TraceScopeIteration: TraceScopeIteration,
+	} {
+		got, err := parseTraceScope(name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := parseTraceScope("vu")
+	assert.Error(t, err)
+}
+
+func TestTracingIterationTraceScope(t *testing.T) {
+	t.Parallel()
+
+	// newTestTracing returns a Tracing instance scoping traces to
+	// iterations, running in the VU context, along with the function
+	// starting a new iteration, and returning its context.
+	newTestTracing := func(t *testing.T) (*Tracing, *modulestest.Runtime, func() context.CancelFunc) {
+		t.Helper()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{
+			vu:         testSetup.VU,
+			root:       New(),
+			propagator: &W3CPropagator{},
+			sampler:    &ProbabilisticSampler{rate: 1},
+			traceScope: TraceScopeIteration,
+		}
+
+		state := &lib.State{VUID: 3, Samples: make(chan metrics.SampleContainer, 100)}
+		testSetup.MoveToVUContext(state)
+
+		parentCtx := lib.WithScenarioState(context.Background(), &lib.ScenarioState{Name: "checkout"})
+		nextIteration := func() context.CancelFunc {
+			ctx, cancel := context.WithCancel(parentCtx)
+			testSetup.VU.CtxField = ctx
+			state.Iteration++
+			return cancel
+		}
+
+		return tracing, testSetup, nextIteration
+	}
+
+	t.Run("requests of an iteration should share its trace", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, testSetup, nextIteration := newTestTracing(t)
+		defer nextIteration()()
+
+		first, err := tracing.injectTraceHeaders(testSetup.VU.Runtime().NewObject())
+		require.NoError(t, err)
+		second, err := tracing.injectTraceHeaders(testSetup.VU.Runtime().NewObject())
+		require.NoError(t, err)
+
+		require.NotNil(t, tracing.iteration)
+		assert.Equal(t, first.TraceID, second.TraceID)
+		assert.NotEqual(t, first.SpanID, second.SpanID)
+		assert.Equal(t, tracing.iteration.span.SpanID, first.ParentSpanID)
+		assert.Equal(t, tracing.iteration.span.SpanID, second.ParentSpanID)
+		assert.Equal(t, map[string]interface{}{
+			k6ScenarioAttributeKey:  "checkout",
+			k6VUAttributeKey:        int64(3),
+			k6IterationAttributeKey: int64(1),
+		}, tracing.iteration.span.Attributes)
+	})
+
+	t.Run("distinct iterations should produce distinct traces", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, testSetup, nextIteration := newTestTracing(t)
+
+		cancel := nextIteration()
+		first, err := tracing.injectTraceHeaders(testSetup.VU.Runtime().NewObject())
+		require.NoError(t, err)
+		cancel()
+
+		defer nextIteration()()
+		second, err := tracing.injectTraceHeaders(testSetup.VU.Runtime().NewObject())
+		require.NoError(t, err)
+
+		assert.NotEqual(t, first.TraceID, second.TraceID)
+		assert.Equal(t, int64(2), tracing.iteration.span.Attributes[k6IterationAttributeKey])
+	})
+
+	t.Run("spans started within an iteration should be children of its root span", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, _, nextIteration := newTestTracing(t)
+		defer nextIteration()()
+
+		span, err := tracing.startSpan("login", nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, tracing.iteration.span.TraceID, span.TraceID)
+		assert.Equal(t, tracing.iteration.span.SpanID, span.span.ParentSpanID)
+	})
+
+	t.Run("the iteration span should be exported once the iteration is done", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, _, nextIteration := newTestTracing(t)
+		exporter := &recordingExporter{}
+		processor, err := newBatchSpanProcessor(exporter, &batchOptions{FlushInterval: "1h"}, &spanProcessorMetrics{}, nil, nil)
+		require.NoError(t, err)
+		tracing.processor = processor
+
+		cancel := nextIteration()
+		_, err = tracing.currentIterationTrace()
+		require.NoError(t, err)
+		cancel()
+
+		require.NoError(t, processor.Shutdown(context.Background(), nil))
+
+		require.Equal(t, []int{1}, exporter.batchSizes())
+		assert.Equal(t, iterationSpanName, exporter.batches[0][0].Name)
+		assert.False(t, exporter.batches[0][0].EndTime.IsZero())
+	})
+}
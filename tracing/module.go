@@ -2,14 +2,65 @@
 package tracing
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+const (
+	// shutdownTimeout is the maximum duration the module waits for its
+	// span processors to shut down, at the end of the test.
+	shutdownTimeout = time.Minute
+
+	// testEndPollInterval is the interval at which the module checks
+	// whether the test has ended, when k6 runs without end-of-test summary
+	// nor tracing output.
+	testEndPollInterval = 100 * time.Millisecond
 )
 
 type (
 	// RootModule is the global module instance that will create Client
 	// instances for each VU.
-	RootModule struct{}
+	//
+	// It holds the state shared by all the VUs of a test, such as the
+	// span processors exporting the spans they record.
+	//
+	// The processors are shut down as soon as k6 initializes the VU
+	// handling the end-of-test summary, which it does once the test has
+	// ended, and waits for before exiting.
+	RootModule struct {
+		mu sync.Mutex
+
+		// processors are the span processors of the test, indexed
+		// by the encoded exporter options they were created for.
+		processors map[string]*batchSpanProcessor
+
+		// output is the tracing output shutting the processors down
+		// at the end of the test, if k6 runs with it.
+		output *Output
+
+		// execution is the execution state of the test run, and samples
+		// the channel its metrics samples are pushed to, as found in the
+		// context of the first VU recording spans.
+		execution *lib.ExecutionState
+		samples   chan<- metrics.SampleContainer
+
+		// shutdown ensures the processors are shut down at most once.
+		shutdown sync.Once
+
+		metrics *spanProcessorMetrics
+		tags    *metrics.TagSet
+	}
 
 	// ModuleInstance represents an instance of the JS module.
 	ModuleInstance struct {
@@ -32,22 +83,204 @@ func New() *RootModule {
 
 // NewModuleInstance implements the modules.Module interface and returns
 // a new instance for each VU.
-func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+func (r *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 	vu.Runtime().SetFieldNameMapper(goja.TagFieldNameMapper("js", true))
 
+	if err := r.registerMetrics(vu.InitEnv().Registry); err != nil {
+		common.Throw(vu.Runtime(), err)
+	}
+
+	// k6 initializes the VU handling the end-of-test summary once the
+	// test has ended, while it still reads the metrics samples.
+	if samples, ended := r.testEnded(); ended {
+		r.shutdownOnTestEnd(vu.InitEnv().Logger, samples)
+	}
+
 	return &ModuleInstance{
 		vu: vu,
 		Tracing: &Tracing{
-			vu: vu,
+			vu:   vu,
+			root: r,
 		},
 	}
 }
 
+// registerMetrics registers the module's metrics in the given registry,
+// unless already done.
+func (r *RootModule) registerMetrics(registry *metrics.Registry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.metrics != nil {
+		return nil
+	}
+
+	m, err := registerSpanProcessorMetrics(registry)
+	if err != nil {
+		return fmt.Errorf("failed to register tracing metrics: %w", err)
+	}
+
+	r.metrics = m
+	r.tags = registry.RootTagSet()
+
+	return nil
+}
+
+// spanProcessor returns the span processor exporting spans with the given
+// exporter options, creating it if necessary.
+//
+// VUs configured with the same exporter options share the same processor.
+func (r *RootModule) spanProcessor(opts exporterOptions, logger logrus.FieldLogger) (*batchSpanProcessor, error) {
+	key, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if processor, ok := r.processors[string(key)]; ok {
+		return processor, nil
+	}
+
+	if r.metrics == nil {
+		return nil, fmt.Errorf("tracing metrics are not registered")
+	}
+
+	exporter, err := newSpanExporter(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	processor, err := newBatchSpanProcessor(exporter, opts.Batch, r.metrics, r.tags, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.processors == nil {
+		r.processors = make(map[string]*batchSpanProcessor)
+	}
+	r.processors[string(key)] = processor
+
+	return processor, nil
+}
+
+// watchTestRun captures the execution state of the test run, and the
+// channel its metrics samples are pushed to, from the given VU running an
+// iteration, unless already done.
+//
+// When k6 runs with neither end-of-test summary nor tracing output, no
+// VU is initialized once the test has ended, and the processors are shut
+// down as soon as the test ends instead, without holding k6 up.
+func (r *RootModule) watchTestRun(vu modules.VU, logger logrus.FieldLogger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.execution != nil {
+		return
+	}
+
+	execution, state := lib.GetExecutionState(vu.Context()), vu.State()
+	if execution == nil || state == nil {
+		return
+	}
+
+	r.execution = execution
+	r.samples = state.Samples
+
+	if r.output != nil || execution.Test == nil || !execution.Test.RuntimeOptions.NoSummary.Bool {
+		return
+	}
+
+	logger.Warnf(
+		"The spans recorded at the end of the test might not be exported, as k6 runs with --no-summary, "+
+			"unless it also runs with the %s output, using --out %s", OutputName, OutputName,
+	)
+
+	go func() {
+		ticker := time.NewTicker(testEndPollInterval)
+		defer ticker.Stop()
+
+		for !execution.HasEnded() {
+			<-ticker.C
+		}
+
+		// By then, k6 might have closed the samples channel already.
+		r.shutdownOnTestEnd(logger, nil)
+	}()
+}
+
+// testEnded returns true once the test run captured by watchTestRun
+// has ended, along with the channel its metrics samples are pushed to.
+func (r *RootModule) testEnded() (chan<- metrics.SampleContainer, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.samples, r.execution != nil && r.execution.HasEnded()
+}
+
+// shutdownOnTestEnd shuts down the span processors of the test, pushing
+// their final metrics to the given samples channel, and logs the failure
+// to do so.
+func (r *RootModule) shutdownOnTestEnd(logger logrus.FieldLogger, samples chan<- metrics.SampleContainer) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := r.shutdownProcessors(ctx, samples); err != nil {
+		logger.WithError(err).Warn("Failed to shut down the tracing span processors")
+	}
+}
+
+// Shutdown shuts down the span processors of the test, exporting the
+// spans they still hold, and closing their exporters.
+//
+// It is meant to be called once the test has ended, and k6 is done with
+// its metrics samples. It returns once the processors are shut down, by
+// this call or a previous one.
+func (r *RootModule) Shutdown(ctx context.Context) error {
+	return r.shutdownProcessors(ctx, nil)
+}
+
+// shutdownProcessors shuts down the span processors of the test, unless
+// already done, pushing their final metrics to the given samples channel.
+//
+// Concurrent calls wait for the first one to shut the processors down, and
+// only the first one reports the failure to do so.
+func (r *RootModule) shutdownProcessors(ctx context.Context, samples chan<- metrics.SampleContainer) error {
+	var errs []string
+
+	r.shutdown.Do(func() {
+		r.mu.Lock()
+		processors := r.processors
+		r.processors = nil
+		r.mu.Unlock()
+
+		for _, processor := range processors {
+			if err := processor.Shutdown(ctx, samples); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	})
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to shut down span processors: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
 // Exports implements the modules.Instance interface and returns
 // the exports of the JS module.
 func (mi *ModuleInstance) Exports() modules.Exports {
 	return modules.Exports{Named: map[string]interface{}{
-		"tracing":        mi.Tracing,
-		"instrumentHTTP": mi.Tracing.InstrumentHTTP,
+		"tracing":              mi.Tracing,
+		"Client":               mi.Tracing.NewClient,
+		"instrumentHTTP":       mi.Tracing.InstrumentHTTP,
+		"startSpan":            mi.Tracing.StartSpan,
+		"withSpan":             mi.Tracing.WithSpan,
+		"instrumentGroups":     mi.Tracing.InstrumentGroups,
+		"instrumentGRPC":       mi.Tracing.InstrumentGRPC,
+		"instrumentWebSockets": mi.Tracing.InstrumentWebSockets,
+		"propagators":          Propagators(),
 	}}
 }
@@ -0,0 +1,139 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// instrumentationScopeName is the name of the instrumentation scope
+// the spans produced by the module are reported under.
+const instrumentationScopeName = "k6/x/tracing"
+
+// newOTLPTraceRequest builds an OTLP export request holding the given spans,
+// reported as originating from a resource named after the given service name.
+func newOTLPTraceRequest(serviceName string, spans []*Span) (*coltracepb.ExportTraceServiceRequest, error) {
+	otlpSpans := make([]*tracepb.Span, 0, len(spans))
+	for _, span := range spans {
+		otlpSpan, err := toOTLPSpan(span)
+		if err != nil {
+			return nil, err
+		}
+
+		otlpSpans = append(otlpSpans, otlpSpan)
+	}
+
+	return &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{
+			{
+				Resource: &resourcepb.Resource{
+					Attributes: toOTLPAttributes(map[string]interface{}{"service.name": serviceName}),
+				},
+				ScopeSpans: []*tracepb.ScopeSpans{
+					{
+						Scope: &commonpb.InstrumentationScope{Name: instrumentationScopeName},
+						Spans: otlpSpans,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// toOTLPSpan converts a Span to its OTLP representation.
+func toOTLPSpan(span *Span) (*tracepb.Span, error) {
+	traceID, err := hex.DecodeString(span.TraceID)
+	if err != nil || len(traceID) != 16 {
+		return nil, fmt.Errorf("invalid trace ID %q", span.TraceID)
+	}
+
+	spanID, err := hex.DecodeString(span.SpanID)
+	if err != nil || len(spanID) != 8 {
+		return nil, fmt.Errorf("invalid span ID %q", span.SpanID)
+	}
+
+	var parentSpanID []byte
+	if span.ParentSpanID != "" {
+		parentSpanID, err = hex.DecodeString(span.ParentSpanID)
+		if err != nil || len(parentSpanID) != 8 {
+			return nil, fmt.Errorf("invalid parent span ID %q", span.ParentSpanID)
+		}
+	}
+
+	events := make([]*tracepb.Span_Event, 0, len(span.Events))
+	for _, event := range span.Events {
+		events = append(events, &tracepb.Span_Event{
+			TimeUnixNano: uint64(event.Time.UnixNano()),
+			Name:         event.Name,
+			Attributes:   toOTLPAttributes(event.Attributes),
+		})
+	}
+
+	return &tracepb.Span{
+		TraceId:           traceID,
+		SpanId:            spanID,
+		ParentSpanId:      parentSpanID,
+		Name:              span.Name,
+		Kind:              tracepb.Span_SpanKind(span.Kind),
+		StartTimeUnixNano: uint64(span.StartTime.UnixNano()),
+		EndTimeUnixNano:   uint64(span.EndTime.UnixNano()),
+		Attributes:        toOTLPAttributes(span.Attributes),
+		Events:            events,
+		Status: &tracepb.Status{
+			Code:    tracepb.Status_StatusCode(span.Status.Code),
+			Message: span.Status.Message,
+		},
+	}, nil
+}
+
+// toOTLPAttributes converts a set of attributes to their OTLP representation,
+// sorted by key.
+func toOTLPAttributes(attributes map[string]interface{}) []*commonpb.KeyValue {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	keyValues := make([]*commonpb.KeyValue, 0, len(attributes))
+	for _, key := range keys {
+		keyValues = append(keyValues, &commonpb.KeyValue{
+			Key:   key,
+			Value: toOTLPValue(attributes[key]),
+		})
+	}
+
+	return keyValues
+}
+
+// toOTLPValue converts an attribute value to its OTLP representation.
+//
+// Values of unsupported types are converted to their string representation.
+func toOTLPValue(value interface{}) *commonpb.AnyValue {
+	switch v := value.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case int:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	case []interface{}:
+		values := make([]*commonpb.AnyValue, 0, len(v))
+		for _, item := range v {
+			values = append(values, toOTLPValue(item))
+		}
+
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(v)}}
+	}
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestToOTLPSpan(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid span should be converted", func(t *testing.T) {
+		t.Parallel()
+
+		startTime := time.Unix(1670000000, 0)
+		span := &Span{
+			TraceID:      testTraceID,
+			SpanID:       testSpanID,
+			ParentSpanID: "0102030405060708",
+			Name:         "HTTP GET",
+			Kind:         SpanKindClient,
+			StartTime:    startTime,
+			EndTime:      startTime.Add(time.Second),
+			Attributes:   map[string]interface{}{"http.status_code": 500, "http.method": "GET"},
+			Events:       []SpanEvent{{Name: "event", Time: startTime}},
+			Status:       SpanStatus{Code: SpanStatusError, Message: "boom"},
+		}
+
+		got, err := toOTLPSpan(span)
+
+		require.NoError(t, err)
+		assert.Len(t, got.TraceId, 16)
+		assert.Equal(t, []byte{0x8e, 0x1d, 0x3a, 0x4b, 0x5c, 0x6f, 0x7a, 0x2b}, got.SpanId)
+		assert.Equal(t, []byte{1, 2, 3, 4, 5, 6, 7, 8}, got.ParentSpanId)
+		assert.Equal(t, "HTTP GET", got.Name)
+		assert.Equal(t, tracepb.Span_SPAN_KIND_CLIENT, got.Kind)
+		assert.Equal(t, uint64(startTime.UnixNano()), got.StartTimeUnixNano)
+		assert.Equal(t, uint64(startTime.Add(time.Second).UnixNano()), got.EndTimeUnixNano)
+		require.Len(t, got.Attributes, 2)
+		assert.Equal(t, "http.method", got.Attributes[0].Key)
+		assert.Equal(t, int64(500), got.Attributes[1].Value.GetIntValue())
+		require.Len(t, got.Events, 1)
+		assert.Equal(t, "event", got.Events[0].Name)
+		assert.Equal(t, tracepb.Status_STATUS_CODE_ERROR, got.Status.Code)
+		assert.Equal(t, "boom", got.Status.Message)
+	})
+
+	t.Run("invalid trace ID should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := toOTLPSpan(&Span{TraceID: "not-hex", SpanID: testSpanID})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid span ID should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := toOTLPSpan(&Span{TraceID: testTraceID, SpanID: "0102"})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestToOTLPValue(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "value", toOTLPValue("value").GetStringValue())
+	assert.Equal(t, true, toOTLPValue(true).GetBoolValue())
+	assert.Equal(t, int64(42), toOTLPValue(42).GetIntValue())
+	assert.Equal(t, int64(42), toOTLPValue(int64(42)).GetIntValue())
+	assert.Equal(t, 4.2, toOTLPValue(4.2).GetDoubleValue())
+	assert.Equal(t, []*commonpb.AnyValue{toOTLPValue("a"), toOTLPValue(int64(1))},
+		toOTLPValue([]interface{}{"a", int64(1)}).GetArrayValue().GetValues())
+	assert.Equal(t, "1s", toOTLPValue(time.Second).GetStringValue())
+}
@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+// OutputName is the name of the tracing output, as passed to k6's
+// --out flag.
+const OutputName = "tracing"
+
+// Output is a k6 output shutting the module's span processors down once
+// the test has ended, so that the spans recorded by the last iterations
+// are exported before k6 exits.
+//
+// The module shuts its processors down on its own when k6 initializes the
+// VU handling the end-of-test summary. As k6 stops its outputs once the
+// test and its summary are done, the output keeps that guarantee when k6
+// runs with --no-summary. The output doesn't output any metric.
+type Output struct {
+	root *RootModule
+}
+
+var _ output.Output = &Output{}
+
+// NewOutput is the tracing output constructor, as registered with
+// output.RegisterExtension.
+//
+// As k6 creates its outputs once the script is initialized, but before the
+// test starts, the module only checks whether it runs with the output once
+// VUs record spans.
+func (r *RootModule) NewOutput(output.Params) (output.Output, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.output = &Output{root: r}
+
+	return r.output, nil
+}
+
+// Description implements the output.Output interface.
+func (o *Output) Description() string {
+	return OutputName + " (shuts down the tracing span exporters)"
+}
+
+// Start implements the output.Output interface.
+func (o *Output) Start() error {
+	return nil
+}
+
+// AddMetricSamples implements the output.Output interface, discarding
+// the given samples.
+func (o *Output) AddMetricSamples([]metrics.SampleContainer) {}
+
+// Stop implements the output.Output interface, shutting the module's
+// span processors down.
+func (o *Output) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return o.root.Shutdown(ctx)
+}
@@ -1,12 +1,179 @@
 package tracing
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+
+	"github.com/dop251/goja"
 )
 
-// Propagator is an interface for trace context propagation
+// Propagator is an interface for trace context propagation.
+//
+// Propagators produce the headers carrying the given span context in their
+// propagation format. The span context is generated once per request, and
+// shared by all the configured propagators.
 type Propagator interface {
-	Propagate(traceID string) (http.Header, error)
+	Propagate(sc SpanContext) (http.Header, error)
+}
+
+// PropagatorOptions are the options propagators are created with, as
+// configured by the script.
+type PropagatorOptions struct {
+	// Debug indicates whether traces are propagated as debug traces, which
+	// the propagation formats supporting it use to force their sampling.
+	Debug bool
+
+	// Runtime is the VU's JS runtime, which propagator functions are
+	// called in.
+	Runtime *goja.Runtime
+}
+
+// newPropagator returns the propagator configured by the given propagator
+// option, either a propagator name or function, or a list of them.
+//
+// A list of several propagators produces a CompositePropagator.
+func newPropagator(option interface{}, opts PropagatorOptions) (Propagator, error) {
+	items, ok := option.([]interface{})
+	if !ok {
+		items = []interface{}{option}
+	}
+
+	if len(items) == 0 {
+		return nil, errors.New("at least one propagator is required")
+	}
+
+	propagators := make([]Propagator, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if name, isName := item.(string); isName {
+			if seen[name] {
+				return nil, fmt.Errorf("duplicate propagator: %s", name)
+			}
+
+			seen[name] = true
+		}
+
+		propagator, err := newListedPropagator(item, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		propagators = append(propagators, propagator)
+	}
+
+	if len(propagators) == 1 {
+		return propagators[0], nil
+	}
+
+	return &CompositePropagator{Propagators: propagators}, nil
+}
+
+// newListedPropagator returns the propagator described by the given item
+// of the propagator option, either a propagator name, or a JS function.
+func newListedPropagator(item interface{}, opts PropagatorOptions) (Propagator, error) {
+	switch value := item.(type) {
+	case string:
+		return newNamedPropagator(value, opts)
+	case func(goja.FunctionCall) goja.Value:
+		return newJSPropagator(opts.Runtime, value)
+	default:
+		return nil, fmt.Errorf("invalid propagator: %v", item)
+	}
+}
+
+// newNamedPropagator returns a new propagator of the given name, created
+// by the factory registered under that name.
+func newNamedPropagator(name string, opts PropagatorOptions) (Propagator, error) {
+	factory, ok := propagatorFactory(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown propagator: %s, available propagators are: %s", name, strings.Join(Propagators(), ", "))
+	}
+
+	propagator := factory(opts)
+	if propagator == nil {
+		return nil, fmt.Errorf("propagator %s could not be created", name)
+	}
+
+	return propagator, nil
+}
+
+func init() {
+	RegisterPropagator(W3CPropagatorName, func(PropagatorOptions) Propagator {
+		return &W3CPropagator{}
+	})
+	RegisterPropagator(B3PropagatorName, func(opts PropagatorOptions) Propagator {
+		return &B3Propagator{Debug: opts.Debug}
+	})
+	RegisterPropagator(B3MultiPropagatorName, func(opts PropagatorOptions) Propagator {
+		return &B3MultiPropagator{Debug: opts.Debug}
+	})
+	RegisterPropagator(JaegerPropagatorName, func(PropagatorOptions) Propagator {
+		return &JaegerPropagator{}
+	})
+	RegisterPropagator(XRayPropagatorName, func(PropagatorOptions) Propagator {
+		return &XRayPropagator{}
+	})
+	RegisterPropagator(DatadogPropagatorName, func(opts PropagatorOptions) Propagator {
+		return &DatadogPropagator{Debug: opts.Debug}
+	})
+	RegisterPropagator(GCPPropagatorName, func(PropagatorOptions) Propagator {
+		return &GCPPropagator{}
+	})
+	RegisterPropagator(OTPropagatorName, func(PropagatorOptions) Propagator {
+		return &OTPropagator{}
+	})
+}
+
+// traceIDGenerator is implemented by the propagators whose format constrains
+// the trace IDs they propagate. Traces propagated by such propagators start
+// with the trace IDs they generate, rather than with the module's own trace
+// IDs.
+type traceIDGenerator interface {
+	newTraceID() (string, error)
+}
+
+// CompositePropagator is a Propagator combining the headers produced by
+// several propagators, all describing the same trace and span IDs, so
+// that services supporting any of the propagation formats join the trace.
+type CompositePropagator struct {
+	// Propagators are the combined propagators.
+	Propagators []Propagator
+}
+
+// Propagate returns the headers produced by each of the combined
+// propagators for the given span context.
+//
+// When several propagators produce the same header, their values
+// are all kept, in the order the propagators are listed in.
+func (p *CompositePropagator) Propagate(sc SpanContext) (http.Header, error) {
+	header := make(http.Header)
+
+	for _, propagator := range p.Propagators {
+		propagated, err := propagator.Propagate(sc)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, values := range propagated {
+			header[key] = append(header[key], values...)
+		}
+	}
+
+	return header, nil
+}
+
+// newTraceID implements the traceIDGenerator interface, generating trace
+// IDs with the first of the combined propagators constraining them, if any.
+func (p *CompositePropagator) newTraceID() (string, error) {
+	for _, propagator := range p.Propagators {
+		if generator, ok := propagator.(traceIDGenerator); ok {
+			return generator.newTraceID()
+		}
+	}
+
+	return newEncodedTraceID()
 }
 
 const (
@@ -25,20 +192,39 @@ const (
 
 	// W3CSampledTraceFlag is the trace-flag value for a sampled trace.
 	W3CSampledTraceFlag = "01"
+
+	// W3CTraceStateHeaderName is the name of the W3C trace state header
+	W3CTraceStateHeaderName = "Tracestate"
 )
 
-// W3CPropagator is a Propagator for the W3C trace context header
+// W3CPropagator is a Propagator for the W3C trace context header.
+//
+// The span context's trace state, if any, is propagated using the W3C trace
+// state header, and its baggage using the W3C baggage header.
 type W3CPropagator struct{}
 
-// Propagate returns a header with a random trace ID in the W3C format
-func (p *W3CPropagator) Propagate(traceID string) (http.Header, error) {
-	parentID := RandHexStringRunes(16)
+// Propagate returns a header with the given span context in the W3C format
+func (p *W3CPropagator) Propagate(sc SpanContext) (http.Header, error) {
+	traceFlag := W3CUnsampledTraceFlag
+	if sc.IsSampled() {
+		traceFlag = W3CSampledTraceFlag
+	}
 
-	return http.Header{
+	header := http.Header{
 		W3CHeaderName: {
-			W3CVersion + "-" + traceID + "-" + parentID + "-" + W3CSampledTraceFlag,
+			W3CVersion + "-" + sc.TraceID + "-" + sc.SpanID + "-" + traceFlag,
 		},
-	}, nil
+	}
+
+	if sc.TraceState != "" {
+		header[W3CTraceStateHeaderName] = []string{sc.TraceState}
+	}
+
+	if len(sc.Baggage) > 0 {
+		header[BaggageHeaderName] = []string{sc.Baggage.String()}
+	}
+
+	return header, nil
 }
 
 const (
@@ -47,19 +233,117 @@ const (
 
 	// B3HeaderName is the name of the B3 trace context header
 	B3HeaderName = B3PropagatorName
+
+	// B3UnsampledState is the sampling state value for an unsampled trace.
+	B3UnsampledState = "0"
+
+	// B3SampledState is the sampling state value for a sampled trace.
+	B3SampledState = "1"
+
+	// B3DebugState is the sampling state value for a debug trace,
+	// which implies that the trace is sampled.
+	B3DebugState = "d"
 )
 
-// B3Propagator is a Propagator for the B3 trace context header
-type B3Propagator struct{}
+// B3Propagator is a Propagator for the B3 trace context header.
+//
+// The parent span ID is only sent for spans having a parent, and the span
+// context's baggage is propagated using one baggage-prefixed header per item.
+type B3Propagator struct {
+	// Debug propagates traces with the debug sampling state.
+	Debug bool
+}
+
+// Propagate returns a header with the given span context in the B3 format
+func (p *B3Propagator) Propagate(sc SpanContext) (http.Header, error) {
+	samplingState := B3UnsampledState
+	switch {
+	case p.Debug:
+		samplingState = B3DebugState
+	case sc.IsSampled():
+		samplingState = B3SampledState
+	}
 
-// Propagate returns a header with a random trace ID in the B3 format
-func (p *B3Propagator) Propagate(traceID string) (http.Header, error) {
-	parentID := RandHexStringRunes(8)
-	parentSpanID := "1"
+	value := sc.TraceID + "-" + sc.SpanID + "-" + samplingState
+	if sc.ParentSpanID != "" {
+		value += "-" + sc.ParentSpanID
+	}
 
-	return http.Header{
-		B3HeaderName: {traceID + "-" + parentID + "-" + parentSpanID},
-	}, nil
+	header := http.Header{
+		B3HeaderName: {value},
+	}
+
+	for _, member := range sc.Baggage {
+		header[B3BaggageHeaderPrefix+member.Key] = []string{percentEncodeBaggageValue(member.Value)}
+	}
+
+	return header, nil
+}
+
+const (
+	// B3MultiPropagatorName is the name of the B3 multiple headers trace
+	// context propagator
+	B3MultiPropagatorName = "b3multi"
+
+	// B3TraceIDHeaderName is the name of the B3 multiple headers trace ID header
+	B3TraceIDHeaderName = "X-B3-TraceId"
+
+	// B3SpanIDHeaderName is the name of the B3 multiple headers span ID header
+	B3SpanIDHeaderName = "X-B3-SpanId"
+
+	// B3ParentSpanIDHeaderName is the name of the B3 multiple headers parent
+	// span ID header
+	B3ParentSpanIDHeaderName = "X-B3-ParentSpanId"
+
+	// B3SampledHeaderName is the name of the B3 multiple headers sampling
+	// state header
+	B3SampledHeaderName = "X-B3-Sampled"
+
+	// B3FlagsHeaderName is the name of the B3 multiple headers flags header
+	B3FlagsHeaderName = "X-B3-Flags"
+
+	// B3DebugFlags is the flags header value for a debug trace.
+	B3DebugFlags = "1"
+)
+
+// B3MultiPropagator is a Propagator for the B3 multiple trace context
+// headers, as read by older B3 implementations.
+//
+// The X-B3-ParentSpanId header is only sent for spans having a parent, and
+// the span context's baggage is propagated using one baggage-prefixed header
+// per item.
+type B3MultiPropagator struct {
+	// Debug propagates traces with the debug flag, in place of the
+	// sampling state, which the debug flag implies.
+	Debug bool
+}
+
+// Propagate returns headers with the given span context in the B3
+// multiple headers format
+func (p *B3MultiPropagator) Propagate(sc SpanContext) (http.Header, error) {
+	header := http.Header{
+		B3TraceIDHeaderName: {sc.TraceID},
+		B3SpanIDHeaderName:  {sc.SpanID},
+	}
+
+	if sc.ParentSpanID != "" {
+		header[B3ParentSpanIDHeaderName] = []string{sc.ParentSpanID}
+	}
+
+	switch {
+	case p.Debug:
+		header[B3FlagsHeaderName] = []string{B3DebugFlags}
+	case sc.IsSampled():
+		header[B3SampledHeaderName] = []string{B3SampledState}
+	default:
+		header[B3SampledHeaderName] = []string{B3UnsampledState}
+	}
+
+	for _, member := range sc.Baggage {
+		header[B3BaggageHeaderPrefix+member.Key] = []string{percentEncodeBaggageValue(member.Value)}
+	}
+
+	return header, nil
 }
 
 const (
@@ -73,18 +357,42 @@ const (
 	// Its value is zero, which is described in the Jaeger documentation as:
 	// "0 value is valid and means “root span” (when not ignored)"
 	JaegerRootSpanID = "0"
+
+	// JaegerUnsampledFlags is the flags value for an unsampled trace.
+	JaegerUnsampledFlags = "0"
+
+	// JaegerSampledFlags is the flags value for a sampled trace, as
+	// the sampled flag is the first bit of the Jaeger flags bitmap.
+	JaegerSampledFlags = "1"
 )
 
-// JaegerPropagator is a Propagator for the Jaeger trace context header
+// JaegerPropagator is a Propagator for the Jaeger trace context header.
+//
+// The span context's parent span ID, if any, is propagated as the header's
+// parent span ID, which is otherwise set to JaegerRootSpanID. The span
+// context's baggage is propagated using one uberctx-prefixed header
+// per item.
 type JaegerPropagator struct{}
 
-// Propagate returns a header with a random trace ID in the Jaeger format
-func (p *JaegerPropagator) Propagate(traceID string) (http.Header, error) {
-	spanID := RandHexStringRunes(8)
-	// flags set to 1 means the span is sampled
-	flags := "1"
+// Propagate returns a header with the given span context in the Jaeger format
+func (p *JaegerPropagator) Propagate(sc SpanContext) (http.Header, error) {
+	flags := JaegerUnsampledFlags
+	if sc.IsSampled() {
+		flags = JaegerSampledFlags
+	}
+
+	parentSpanID := JaegerRootSpanID
+	if sc.ParentSpanID != "" {
+		parentSpanID = sc.ParentSpanID
+	}
+
+	header := http.Header{
+		JaegerHeaderName: {sc.TraceID + ":" + sc.SpanID + ":" + parentSpanID + ":" + flags},
+	}
+
+	for _, member := range sc.Baggage {
+		header[JaegerBaggageHeaderPrefix+member.Key] = []string{percentEncodeBaggageValue(member.Value)}
+	}
 
-	return http.Header{
-		JaegerHeaderName: {traceID + ":" + spanID + ":" + JaegerRootSpanID + ":" + flags},
-	}, nil
+	return header, nil
 }
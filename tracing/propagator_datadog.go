@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// DatadogPropagatorName is the name of the Datadog trace context propagator
+	DatadogPropagatorName = "datadog"
+
+	// DatadogTraceIDHeaderName is the name of the Datadog trace ID header
+	DatadogTraceIDHeaderName = "x-datadog-trace-id"
+
+	// DatadogParentIDHeaderName is the name of the Datadog parent span ID header
+	DatadogParentIDHeaderName = "x-datadog-parent-id"
+
+	// DatadogSamplingPriorityHeaderName is the name of the Datadog sampling
+	// priority header
+	DatadogSamplingPriorityHeaderName = "x-datadog-sampling-priority"
+
+	// DatadogTagsHeaderName is the name of the Datadog propagated tags header
+	DatadogTagsHeaderName = "x-datadog-tags"
+
+	// DatadogTraceIDHighTagName is the name of the Datadog propagated tag
+	// holding the upper 64 bits of 128 bits trace IDs, hex encoded.
+	DatadogTraceIDHighTagName = "_dd.p.tid"
+
+	// DatadogAutoRejectPriority is the sampling priority of an unsampled trace.
+	DatadogAutoRejectPriority = "0"
+
+	// DatadogAutoKeepPriority is the sampling priority of a sampled trace.
+	DatadogAutoKeepPriority = "1"
+
+	// DatadogUserKeepPriority is the sampling priority of a debug trace,
+	// which the user asked to keep.
+	DatadogUserKeepPriority = "2"
+)
+
+// DatadogPropagator is a Propagator for the Datadog trace context headers.
+//
+// Datadog trace and span IDs are 64 bits integers, sent in decimal. The
+// lower 64 bits of the module's 128 bits trace IDs are thus sent as trace
+// ID, and their upper 64 bits as the _dd.p.tid propagated tag, which
+// Datadog tracers use to restore the full trace ID. The span context's
+// baggage is propagated using one ot-baggage-prefixed header per item.
+type DatadogPropagator struct {
+	// Debug propagates traces with the user keep sampling priority.
+	Debug bool
+}
+
+// Propagate returns headers with the given span context in the Datadog format
+func (p *DatadogPropagator) Propagate(sc SpanContext) (http.Header, error) {
+	if len(sc.TraceID) != 32 {
+		return nil, fmt.Errorf("invalid trace ID: %s", sc.TraceID)
+	}
+
+	traceIDHigh, traceIDLow := sc.TraceID[:16], sc.TraceID[16:]
+
+	decimalTraceID, err := hexToDecimal(traceIDLow)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace ID: %s", sc.TraceID)
+	}
+
+	decimalSpanID, err := hexToDecimal(sc.SpanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid span ID: %s", sc.SpanID)
+	}
+
+	samplingPriority := DatadogAutoRejectPriority
+	switch {
+	case p.Debug:
+		samplingPriority = DatadogUserKeepPriority
+	case sc.IsSampled():
+		samplingPriority = DatadogAutoKeepPriority
+	}
+
+	header := http.Header{
+		DatadogTraceIDHeaderName:          {decimalTraceID},
+		DatadogParentIDHeaderName:         {decimalSpanID},
+		DatadogSamplingPriorityHeaderName: {samplingPriority},
+		DatadogTagsHeaderName:             {DatadogTraceIDHighTagName + "=" + traceIDHigh},
+	}
+
+	for _, member := range sc.Baggage {
+		header[OTBaggageHeaderPrefix+member.Key] = []string{percentEncodeBaggageValue(member.Value)}
+	}
+
+	return header, nil
+}
+
+// hexToDecimal converts the given hex encoded 64 bits unsigned integer
+// to its decimal representation.
+func hexToDecimal(hexID string) (string, error) {
+	id, err := strconv.ParseUint(hexID, 16, 64)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatUint(id, 10), nil
+}
@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatadogPropagatorPropagate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sampled trace should set the auto keep sampling priority", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&DatadogPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+		require.NoError(t, err)
+
+		assert.Equal(t, http.Header{
+			DatadogTraceIDHeaderName:          {"12362280634580618093"},
+			DatadogParentIDHeaderName:         {"10240405223058143787"},
+			DatadogSamplingPriorityHeaderName: {DatadogAutoKeepPriority},
+			DatadogTagsHeaderName:             {"_dd.p.tid=dc0718cbc15e0d42"},
+		}, header)
+	})
+
+	t.Run("unsampled trace should set the auto reject sampling priority", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&DatadogPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, false))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{DatadogAutoRejectPriority}, header[DatadogSamplingPriorityHeaderName])
+	})
+
+	t.Run("debug trace should set the user keep sampling priority", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&DatadogPropagator{Debug: true}).Propagate(newTestSpanContext(testTraceID, testSpanID, false))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{DatadogUserKeepPriority}, header[DatadogSamplingPriorityHeaderName])
+	})
+
+	t.Run("baggage should be set as ot-baggage-prefixed headers", func(t *testing.T) {
+		t.Parallel()
+
+		baggage := Baggage{{Key: "tenant", Value: "acme corp"}}
+		sc := newTestSpanContext(testTraceID, testSpanID, true)
+		sc.Baggage = baggage
+
+		header, err := (&DatadogPropagator{}).Propagate(sc)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"acme%20corp"}, header["ot-baggage-tenant"])
+	})
+
+	t.Run("invalid IDs should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := (&DatadogPropagator{}).Propagate(newTestSpanContext("dc0718cb", testSpanID, true))
+		assert.Error(t, err)
+
+		_, err = (&DatadogPropagator{}).Propagate(newTestSpanContext(testTraceID, "not-a-span-id", true))
+		assert.Error(t, err)
+	})
+}
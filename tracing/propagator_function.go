@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dop251/goja"
+)
+
+// JSPropagator is a Propagator calling a user-defined JS function, for
+// propagation formats the module doesn't support out of the box.
+//
+// The function is called with a span context object, holding the traceId,
+// spanId, parentSpanId, sampled, traceState, and baggage properties, the
+// latter being an object of baggage item values, and returns an object of the
+// headers to set, such as:
+//
+//	(ctx) => ({ "X-Req-Trace": `${ctx.traceId}:${ctx.spanId}` })
+//
+// Headers with a nullish value are not set.
+type JSPropagator struct {
+	rt        *goja.Runtime
+	propagate goja.Callable
+}
+
+// newJSPropagator returns a new JSPropagator calling the given exported JS
+// function in the given runtime.
+func newJSPropagator(rt *goja.Runtime, fn func(goja.FunctionCall) goja.Value) (*JSPropagator, error) {
+	if rt == nil {
+		return nil, errors.New("propagator functions require a JS runtime")
+	}
+
+	// Wrapping the exported function in a runtime function lets
+	// exceptions it throws be returned as errors.
+	propagate, ok := goja.AssertFunction(rt.ToValue(fn))
+	if !ok {
+		return nil, errors.New("invalid propagator function")
+	}
+
+	return &JSPropagator{rt: rt, propagate: propagate}, nil
+}
+
+// Propagate returns the headers produced by the propagator function for the
+// given trace ID and span ID.
+func (p *JSPropagator) Propagate(sc SpanContext) (http.Header, error) {
+	baggage := p.rt.NewObject()
+	for _, member := range sc.Baggage {
+		// Setting a property on a freshly created object cannot fail.
+		_ = baggage.Set(member.Key, member.Value)
+	}
+
+	ctx := p.rt.NewObject()
+	_ = ctx.Set("traceId", sc.TraceID)
+	_ = ctx.Set("spanId", sc.SpanID)
+	_ = ctx.Set("parentSpanId", sc.ParentSpanID)
+	_ = ctx.Set("sampled", sc.IsSampled())
+	_ = ctx.Set("traceState", sc.TraceState)
+	_ = ctx.Set("baggage", baggage)
+
+	result, err := p.propagate(goja.Undefined(), ctx)
+	if err != nil {
+		return nil, fmt.Errorf("propagator function failed: %w", err)
+	}
+
+	header := make(http.Header)
+	if isNullish(result) {
+		return header, nil
+	}
+
+	headers, ok := result.(*goja.Object)
+	if !ok {
+		return nil, fmt.Errorf("propagator function must return an object of headers, got %s", result.String())
+	}
+
+	for _, key := range headers.Keys() {
+		value := headers.Get(key)
+		if isNullish(value) {
+			continue
+		}
+
+		header[key] = []string{value.String()}
+	}
+
+	return header, nil
+}
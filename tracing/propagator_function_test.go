@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestJSPropagator returns a JSPropagator calling the function the given
+// script evaluates to.
+func newTestJSPropagator(t *testing.T, script string) Propagator {
+	t.Helper()
+
+	rt := goja.New()
+
+	fn, err := rt.RunString(script)
+	require.NoError(t, err)
+
+	propagator, err := newPropagator(fn.Export(), PropagatorOptions{Runtime: rt})
+	require.NoError(t, err)
+
+	return propagator
+}
+
+func TestJSPropagatorPropagate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("the function should produce the headers from the span context", func(t *testing.T) {
+		t.Parallel()
+
+		propagator := newTestJSPropagator(t, `
+			(ctx) => ({
+				"X-Req-Trace": ctx.traceId + ":" + ctx.spanId + ":" + ctx.parentSpanId + ":" + (ctx.sampled ? "1" : "0"),
+				"X-Req-State": ctx.traceState,
+				"X-Req-Tenant": ctx.baggage.tenant,
+				"X-Req-Unset": undefined,
+			})
+		`)
+
+		sc := newTestSpanContext(testTraceID, testSpanID, true)
+		sc.ParentSpanID = "00f067aa0ba902b7"
+		sc.TraceState = "k6=1"
+		sc.Baggage = Baggage{{Key: "tenant", Value: "acme"}}
+
+		header, err := propagator.Propagate(sc)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.Header{
+			"X-Req-Trace":  {testTraceID + ":" + testSpanID + ":00f067aa0ba902b7:1"},
+			"X-Req-State":  {"k6=1"},
+			"X-Req-Tenant": {"acme"},
+		}, header)
+	})
+
+	t.Run("a nullish result should produce no headers", func(t *testing.T) {
+		t.Parallel()
+
+		propagator := newTestJSPropagator(t, `() => null`)
+
+		header, err := propagator.Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+		require.NoError(t, err)
+
+		assert.Empty(t, header)
+	})
+
+	t.Run("a non-object result should fail", func(t *testing.T) {
+		t.Parallel()
+
+		propagator := newTestJSPropagator(t, `() => "X-Req-Trace"`)
+
+		_, err := propagator.Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+
+		assert.Error(t, err)
+	})
+
+	t.Run("exceptions thrown by the function should be returned", func(t *testing.T) {
+		t.Parallel()
+
+		propagator := newTestJSPropagator(t, `() => { throw new Error("boom") }`)
+
+		_, err := propagator.Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+
+		assert.ErrorContains(t, err, "boom")
+	})
+
+	t.Run("functions should be listable alongside named propagators", func(t *testing.T) {
+		t.Parallel()
+
+		rt := goja.New()
+
+		fn, err := rt.RunString(`(ctx) => ({"X-Req-Trace": ctx.traceId})`)
+		require.NoError(t, err)
+
+		propagator, err := newPropagator([]interface{}{W3CPropagatorName, fn.Export()}, PropagatorOptions{Runtime: rt})
+		require.NoError(t, err)
+
+		header, err := propagator.Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"00-" + testTraceID + "-" + testSpanID + "-01"}, header[W3CHeaderName])
+		assert.Equal(t, []string{testTraceID}, header["X-Req-Trace"])
+	})
+}
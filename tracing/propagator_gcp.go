@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const (
+	// GCPPropagatorName is the name of the Google Cloud trace context propagator
+	GCPPropagatorName = "gcp"
+
+	// GCPHeaderName is the name of the Google Cloud trace context header
+	GCPHeaderName = "X-Cloud-Trace-Context"
+
+	// GCPUnsampledOption is the trace option value for an unsampled trace.
+	GCPUnsampledOption = "0"
+
+	// GCPSampledOption is the trace option value for a sampled trace.
+	GCPSampledOption = "1"
+)
+
+// GCPPropagator is a Propagator for the Google Cloud trace context header,
+// as used by Google Cloud load balancers and Cloud Trace.
+//
+// The header holds the hex encoded trace ID, followed by the span ID as
+// a decimal unsigned integer, and the sampling option bit.
+//
+// The Google Cloud format doesn't carry baggage, which is thus not
+// propagated.
+type GCPPropagator struct{}
+
+// Propagate returns a header with the given span context in the Google Cloud format
+func (p *GCPPropagator) Propagate(sc SpanContext) (http.Header, error) {
+	if len(sc.TraceID) != 32 {
+		return nil, fmt.Errorf("invalid trace ID: %s", sc.TraceID)
+	}
+
+	decimalSpanID, err := hexToDecimal(sc.SpanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid span ID: %s", sc.SpanID)
+	}
+
+	option := GCPUnsampledOption
+	if sc.IsSampled() {
+		option = GCPSampledOption
+	}
+
+	header := http.Header{
+		GCPHeaderName: {sc.TraceID + "/" + decimalSpanID + ";o=" + option},
+	}
+
+	return header, nil
+}
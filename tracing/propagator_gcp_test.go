@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCPPropagatorPropagate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sampled trace should set the sampled option", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&GCPPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{testTraceID + "/10240405223058143787;o=1"}, header[GCPHeaderName])
+	})
+
+	t.Run("unsampled trace should unset the sampled option", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&GCPPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, false))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{testTraceID + "/10240405223058143787;o=0"}, header[GCPHeaderName])
+	})
+
+	t.Run("invalid IDs should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := (&GCPPropagator{}).Propagate(newTestSpanContext("dc0718cb", testSpanID, true))
+		assert.Error(t, err)
+
+		_, err = (&GCPPropagator{}).Propagate(newTestSpanContext(testTraceID, "not-a-span-id", true))
+		assert.Error(t, err)
+	})
+}
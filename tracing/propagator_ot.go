@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const (
+	// OTPropagatorName is the name of the OpenTracing trace context propagator
+	OTPropagatorName = "ot"
+
+	// OTTraceIDHeaderName is the name of the OpenTracing trace ID header
+	OTTraceIDHeaderName = "ot-tracer-traceid"
+
+	// OTSpanIDHeaderName is the name of the OpenTracing span ID header
+	OTSpanIDHeaderName = "ot-tracer-spanid"
+
+	// OTSampledHeaderName is the name of the OpenTracing sampled header
+	OTSampledHeaderName = "ot-tracer-sampled"
+
+	// OTUnsampledValue is the sampled header value for an unsampled trace.
+	OTUnsampledValue = "false"
+
+	// OTSampledValue is the sampled header value for a sampled trace.
+	OTSampledValue = "true"
+)
+
+// OTPropagator is a Propagator for the OpenTracing trace context headers,
+// as read by the Lightstep and other OpenTracing basic tracers.
+//
+// OpenTracing tracers only support 64 bits trace IDs. The module's 128 bits
+// trace IDs are thus truncated to their lower 64 bits, as the OpenTelemetry
+// OT propagator does, while 64 bits trace IDs are sent as is. The span
+// context's baggage is propagated using one ot-baggage-prefixed header per
+// item.
+type OTPropagator struct{}
+
+// Propagate returns headers with the given span context in the OpenTracing format
+func (p *OTPropagator) Propagate(sc SpanContext) (http.Header, error) {
+	traceID := sc.TraceID
+	switch len(traceID) {
+	case 16:
+	case 32:
+		traceID = traceID[16:]
+	default:
+		return nil, fmt.Errorf("invalid trace ID: %s", traceID)
+	}
+
+	sampledValue := OTUnsampledValue
+	if sc.IsSampled() {
+		sampledValue = OTSampledValue
+	}
+
+	header := http.Header{
+		OTTraceIDHeaderName: {traceID},
+		OTSpanIDHeaderName:  {sc.SpanID},
+		OTSampledHeaderName: {sampledValue},
+	}
+
+	for _, member := range sc.Baggage {
+		header[OTBaggageHeaderPrefix+member.Key] = []string{percentEncodeBaggageValue(member.Value)}
+	}
+
+	return header, nil
+}
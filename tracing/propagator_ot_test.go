@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTPropagatorPropagate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("128 bits trace IDs should be truncated to their lower 64 bits", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&OTPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+		require.NoError(t, err)
+
+		assert.Equal(t, http.Header{
+			OTTraceIDHeaderName: {testTraceID[16:]},
+			OTSpanIDHeaderName:  {testSpanID},
+			OTSampledHeaderName: {OTSampledValue},
+		}, header)
+	})
+
+	t.Run("64 bits trace IDs should be sent as is", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&OTPropagator{}).Propagate(newTestSpanContext(testTraceID[:16], testSpanID, true))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{testTraceID[:16]}, header[OTTraceIDHeaderName])
+	})
+
+	t.Run("unsampled trace should unset the sampled header", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&OTPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, false))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{OTUnsampledValue}, header[OTSampledHeaderName])
+	})
+
+	t.Run("baggage should be set as ot-baggage-prefixed headers", func(t *testing.T) {
+		t.Parallel()
+
+		baggage := Baggage{{Key: "tenant", Value: "acme corp"}}
+		sc := newTestSpanContext(testTraceID, testSpanID, true)
+		sc.Baggage = baggage
+
+		header, err := (&OTPropagator{}).Propagate(sc)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"acme%20corp"}, header["ot-baggage-tenant"])
+	})
+
+	t.Run("invalid trace IDs should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := (&OTPropagator{}).Propagate(newTestSpanContext("dc0718cb", testSpanID, true))
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PropagatorFactory creates a propagator with the given options.
+type PropagatorFactory func(opts PropagatorOptions) Propagator
+
+// propagatorRegistry holds the factories of the available propagators,
+// indexed by the names scripts refer to them with.
+//
+//nolint:gochecknoglobals
+var propagatorRegistry = struct {
+	mu        sync.RWMutex
+	factories map[string]PropagatorFactory
+}{
+	factories: make(map[string]PropagatorFactory),
+}
+
+// RegisterPropagator makes the propagator created by the given factory
+// available to scripts under the given name.
+//
+// It is meant to be called from the init function of the xk6 extensions
+// contributing propagation formats. As modules.Register does, it panics
+// if a propagator is already registered under the same name.
+func RegisterPropagator(name string, factory PropagatorFactory) {
+	if name == "" {
+		panic("propagator name is required")
+	}
+
+	if factory == nil {
+		panic(fmt.Sprintf("propagator %s has no factory", name))
+	}
+
+	propagatorRegistry.mu.Lock()
+	defer propagatorRegistry.mu.Unlock()
+
+	if _, ok := propagatorRegistry.factories[name]; ok {
+		panic(fmt.Sprintf("propagator already registered: %s", name))
+	}
+
+	propagatorRegistry.factories[name] = factory
+}
+
+// Propagators returns the sorted names of the available propagators.
+func Propagators() []string {
+	propagatorRegistry.mu.RLock()
+	defer propagatorRegistry.mu.RUnlock()
+
+	names := make([]string, 0, len(propagatorRegistry.factories))
+	for name := range propagatorRegistry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// propagatorFactory returns the factory registered under the given name.
+func propagatorFactory(name string) (PropagatorFactory, bool) {
+	propagatorRegistry.mu.RLock()
+	defer propagatorRegistry.mu.RUnlock()
+
+	factory, ok := propagatorRegistry.factories[name]
+
+	return factory, ok
+}
@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/js/modulestest"
+)
+
+// staticPropagator is a test Propagator producing the same headers
+// for every trace.
+type staticPropagator struct {
+	header http.Header
+}
+
+func (p *staticPropagator) Propagate(SpanContext) (http.Header, error) {
+	return p.header, nil
+}
+
+// registerTestPropagator registers the given factory for the duration
+// of the test, so that tests can be run repeatedly.
+func registerTestPropagator(t *testing.T, name string, factory PropagatorFactory) {
+	t.Helper()
+
+	RegisterPropagator(name, factory)
+
+	t.Cleanup(func() {
+		propagatorRegistry.mu.Lock()
+		defer propagatorRegistry.mu.Unlock()
+
+		delete(propagatorRegistry.factories, name)
+	})
+}
+
+func TestRegisterPropagator(t *testing.T) {
+	t.Parallel()
+
+	t.Run("registered propagators should be available by name", func(t *testing.T) {
+		t.Parallel()
+
+		registerTestPropagator(t, "test-static", func(opts PropagatorOptions) Propagator {
+			return &staticPropagator{header: http.Header{"X-Static": {strconv.FormatBool(opts.Debug)}}}
+		})
+
+		assert.Contains(t, Propagators(), "test-static")
+
+		propagator, err := newPropagator("test-static", PropagatorOptions{Debug: true})
+		require.NoError(t, err)
+
+		header, err := propagator.Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+		require.NoError(t, err)
+		assert.Equal(t, http.Header{"X-Static": {"true"}}, header)
+	})
+
+	t.Run("registering a propagator name twice should panic", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Panics(t, func() {
+			RegisterPropagator(W3CPropagatorName, func(PropagatorOptions) Propagator { return &W3CPropagator{} })
+		})
+	})
+
+	t.Run("factories not creating a propagator should fail", func(t *testing.T) {
+		t.Parallel()
+
+		registerTestPropagator(t, "test-nil", func(PropagatorOptions) Propagator { return nil })
+
+		_, err := newPropagator("test-nil", PropagatorOptions{})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestPropagators(t *testing.T) {
+	t.Parallel()
+
+	names := Propagators()
+
+	assert.IsIncreasing(t, names)
+	assert.Subset(t, names, []string{
+		W3CPropagatorName, B3PropagatorName, B3MultiPropagatorName, JaegerPropagatorName,
+		XRayPropagatorName, DatadogPropagatorName, GCPPropagatorName, OTPropagatorName,
+	})
+}
+
+func TestModuleInstanceExportsPropagators(t *testing.T) {
+	t.Parallel()
+
+	testSetup := modulestest.NewRuntime(t)
+	rt := testSetup.VU.Runtime()
+
+	mi, ok := New().NewModuleInstance(testSetup.VU).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("propagators", mi.Exports().Named["propagators"]))
+
+	got, err := rt.RunString(`propagators.includes("w3c")`)
+
+	require.NoError(t, err)
+	assert.True(t, got.ToBoolean())
+}
@@ -0,0 +1,332 @@
+package tracing
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTraceID = "dc0718cbc15e0d42ab8fa4bd2dde4b6d"
+	testSpanID  = "8e1d3a4b5c6f7a2b"
+)
+
+// newTestSpanContext returns the span context of a span of the given trace,
+// without parent, trace state, nor baggage.
+func newTestSpanContext(traceID, spanID string, sampled bool) SpanContext {
+	return SpanContext{TraceID: traceID, SpanID: spanID, TraceFlags: newTraceFlags(sampled)}
+}
+
+func TestW3CPropagatorPropagate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sampled trace should set the sampled trace flag", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&W3CPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+		require.NoError(t, err)
+
+		parts := strings.Split(header[W3CHeaderName][0], "-")
+		require.Len(t, parts, 4)
+		assert.Equal(t, W3CVersion, parts[0])
+		assert.Equal(t, testTraceID, parts[1])
+		assert.Equal(t, testSpanID, parts[2])
+		assert.Equal(t, W3CSampledTraceFlag, parts[3])
+	})
+
+	t.Run("unsampled trace should set the unsampled trace flag", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&W3CPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, false))
+		require.NoError(t, err)
+
+		parts := strings.Split(header[W3CHeaderName][0], "-")
+		require.Len(t, parts, 4)
+		assert.Equal(t, W3CUnsampledTraceFlag, parts[3])
+		assert.NotContains(t, header, W3CTraceStateHeaderName)
+	})
+
+	t.Run("trace state should set the tracestate header", func(t *testing.T) {
+		t.Parallel()
+
+		sc := newTestSpanContext(testTraceID, testSpanID, true)
+		sc.TraceState = "k6=1,vendor=value"
+
+		header, err := (&W3CPropagator{}).Propagate(sc)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"k6=1,vendor=value"}, header[W3CTraceStateHeaderName])
+	})
+}
+
+func TestB3PropagatorPropagate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sampled trace should set the sampled state", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&B3Propagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+		require.NoError(t, err)
+
+		parts := strings.Split(header[B3HeaderName][0], "-")
+		require.Len(t, parts, 3)
+		assert.Equal(t, testTraceID, parts[0])
+		assert.Equal(t, testSpanID, parts[1])
+		assert.Equal(t, B3SampledState, parts[2])
+	})
+
+	t.Run("unsampled trace should set the unsampled state", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&B3Propagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, false))
+		require.NoError(t, err)
+
+		parts := strings.Split(header[B3HeaderName][0], "-")
+		require.Len(t, parts, 3)
+		assert.Equal(t, B3UnsampledState, parts[2])
+	})
+
+	t.Run("debug trace should set the debug state", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&B3Propagator{Debug: true}).Propagate(newTestSpanContext(testTraceID, testSpanID, false))
+		require.NoError(t, err)
+
+		parts := strings.Split(header[B3HeaderName][0], "-")
+		require.Len(t, parts, 3)
+		assert.Equal(t, B3DebugState, parts[2])
+	})
+
+	t.Run("child span should set the parent span ID", func(t *testing.T) {
+		t.Parallel()
+
+		sc := newTestSpanContext(testTraceID, testSpanID, true)
+		sc.ParentSpanID = "00f067aa0ba902b7"
+
+		header, err := (&B3Propagator{}).Propagate(sc)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{testTraceID + "-" + testSpanID + "-1-00f067aa0ba902b7"}, header[B3HeaderName])
+	})
+}
+
+func TestB3MultiPropagatorPropagate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sampled trace should set the sampled header", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&B3MultiPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+		require.NoError(t, err)
+
+		assert.Equal(t, http.Header{
+			B3TraceIDHeaderName: {testTraceID},
+			B3SpanIDHeaderName:  {testSpanID},
+			B3SampledHeaderName: {B3SampledState},
+		}, header)
+	})
+
+	t.Run("unsampled trace should unset the sampled header", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&B3MultiPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, false))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{B3UnsampledState}, header[B3SampledHeaderName])
+		assert.NotContains(t, header, B3FlagsHeaderName)
+	})
+
+	t.Run("debug trace should set the debug flag in place of the sampled header", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&B3MultiPropagator{Debug: true}).Propagate(newTestSpanContext(testTraceID, testSpanID, false))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{B3DebugFlags}, header[B3FlagsHeaderName])
+		assert.NotContains(t, header, B3SampledHeaderName)
+	})
+
+	t.Run("child span should set the parent span ID header", func(t *testing.T) {
+		t.Parallel()
+
+		sc := newTestSpanContext(testTraceID, testSpanID, true)
+		sc.ParentSpanID = "00f067aa0ba902b7"
+
+		header, err := (&B3MultiPropagator{}).Propagate(sc)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"00f067aa0ba902b7"}, header[B3ParentSpanIDHeaderName])
+	})
+}
+
+func TestJaegerPropagatorPropagate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sampled trace should set the sampled flag", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&JaegerPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+		require.NoError(t, err)
+
+		parts := strings.Split(header[JaegerHeaderName][0], ":")
+		require.Len(t, parts, 4)
+		assert.Equal(t, testTraceID, parts[0])
+		assert.Equal(t, testSpanID, parts[1])
+		assert.Equal(t, JaegerRootSpanID, parts[2])
+		assert.Equal(t, JaegerSampledFlags, parts[3])
+	})
+
+	t.Run("child span should set its parent span ID", func(t *testing.T) {
+		t.Parallel()
+
+		sc := newTestSpanContext(testTraceID, testSpanID, true)
+		sc.ParentSpanID = "00f067aa0ba902b7"
+
+		header, err := (&JaegerPropagator{}).Propagate(sc)
+		require.NoError(t, err)
+
+		parts := strings.Split(header[JaegerHeaderName][0], ":")
+		require.Len(t, parts, 4)
+		assert.Equal(t, testSpanID, parts[1])
+		assert.Equal(t, "00f067aa0ba902b7", parts[2])
+	})
+
+	t.Run("unsampled trace should unset the sampled flag", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&JaegerPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, false))
+		require.NoError(t, err)
+
+		parts := strings.Split(header[JaegerHeaderName][0], ":")
+		require.Len(t, parts, 4)
+		assert.Equal(t, JaegerUnsampledFlags, parts[3])
+	})
+}
+
+func TestPropagatorsBaggage(t *testing.T) {
+	t.Parallel()
+
+	baggage := Baggage{
+		{Key: "scenario", Value: "my scenario"},
+		{Key: "tenant", Value: "acme", Properties: []BaggageProperty{{Key: "ttl", Value: "60"}}},
+	}
+
+	sc := newTestSpanContext(testTraceID, testSpanID, true)
+	sc.Baggage = baggage
+
+	t.Run("W3C propagator should set the baggage header", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&W3CPropagator{}).Propagate(sc)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"scenario=my%20scenario,tenant=acme;ttl=60"}, header[BaggageHeaderName])
+	})
+
+	t.Run("W3C propagator without baggage should not set the baggage header", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&W3CPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+		require.NoError(t, err)
+
+		assert.NotContains(t, header, BaggageHeaderName)
+	})
+
+	t.Run("B3 propagator should set baggage-prefixed headers", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&B3Propagator{}).Propagate(sc)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"my%20scenario"}, header["baggage-scenario"])
+		assert.Equal(t, []string{"acme"}, header["baggage-tenant"])
+	})
+
+	t.Run("B3 multi propagator should set baggage-prefixed headers", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&B3MultiPropagator{}).Propagate(sc)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"my%20scenario"}, header["baggage-scenario"])
+		assert.Equal(t, []string{"acme"}, header["baggage-tenant"])
+	})
+
+	t.Run("Jaeger propagator should set uberctx-prefixed headers", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&JaegerPropagator{}).Propagate(sc)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"my%20scenario"}, header["uberctx-scenario"])
+		assert.Equal(t, []string{"acme"}, header["uberctx-tenant"])
+	})
+}
+
+func TestCompositePropagatorPropagate(t *testing.T) {
+	t.Parallel()
+
+	propagator := &CompositePropagator{Propagators: []Propagator{&W3CPropagator{}, &B3Propagator{}}}
+
+	header, err := propagator.Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"00-" + testTraceID + "-" + testSpanID + "-01"}, header[W3CHeaderName])
+	assert.Equal(t, []string{testTraceID + "-" + testSpanID + "-1"}, header[B3HeaderName])
+}
+
+func TestNewPropagator(t *testing.T) {
+	t.Parallel()
+
+	t.Run("a propagator name should produce the named propagator", func(t *testing.T) {
+		t.Parallel()
+
+		propagator, err := newPropagator(JaegerPropagatorName, PropagatorOptions{})
+
+		require.NoError(t, err)
+		assert.IsType(t, &JaegerPropagator{}, propagator)
+	})
+
+	t.Run("a single propagator list should produce the listed propagator", func(t *testing.T) {
+		t.Parallel()
+
+		propagator, err := newPropagator([]interface{}{W3CPropagatorName}, PropagatorOptions{})
+
+		require.NoError(t, err)
+		assert.IsType(t, &W3CPropagator{}, propagator)
+	})
+
+	t.Run("a propagators list should produce a composite propagator", func(t *testing.T) {
+		t.Parallel()
+
+		propagator, err := newPropagator([]interface{}{W3CPropagatorName, B3PropagatorName}, PropagatorOptions{Debug: true})
+
+		require.NoError(t, err)
+		assert.Equal(t, &CompositePropagator{Propagators: []Propagator{
+			&W3CPropagator{},
+			&B3Propagator{Debug: true},
+		}}, propagator)
+	})
+
+	for name, option := range map[string]interface{}{
+		"an unknown propagator":     "unknown",
+		"an unknown listed one":     []interface{}{W3CPropagatorName, "unknown"},
+		"a duplicate propagator":    []interface{}{W3CPropagatorName, W3CPropagatorName},
+		"an empty propagators list": []interface{}{},
+		"a non-string propagator":   []interface{}{42},
+		"a missing propagator":      nil,
+	} {
+		option := option
+
+		t.Run(name+" should fail", func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newPropagator(option, PropagatorOptions{})
+
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package tracing
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+const (
+	// XRayPropagatorName is the name of the AWS X-Ray trace context propagator
+	XRayPropagatorName = "xray"
+
+	// XRayHeaderName is the name of the AWS X-Ray trace context header
+	XRayHeaderName = "X-Amzn-Trace-Id"
+
+	// XRayTraceIDVersion is the version of the supported X-Ray trace IDs.
+	XRayTraceIDVersion = "1"
+
+	// XRayUnsampledFlag is the Sampled field value for an unsampled trace.
+	XRayUnsampledFlag = "0"
+
+	// XRaySampledFlag is the Sampled field value for a sampled trace.
+	XRaySampledFlag = "1"
+
+	// XRayMaxTraceAge is the maximum age of the trace IDs X-Ray accepts.
+	XRayMaxTraceAge = 30 * 24 * time.Hour
+
+	// XRayMaxClockSkew is the maximum time X-Ray trace IDs are allowed
+	// to be started in the future.
+	XRayMaxClockSkew = 5 * time.Minute
+)
+
+// XRayPropagator is a Propagator for the AWS X-Ray trace context header.
+//
+// X-Ray trace IDs are made of the trace's start time, in epoch seconds,
+// followed by a 96 bits identifier. As X-Ray rejects trace IDs that were
+// not started recently, traces propagated by the X-Ray propagator start
+// with X-Ray trace IDs, rather than with the module's own trace IDs. The
+// X-Ray trace ID thus holds the very trace ID attached to the requests
+// metadata and exported spans.
+//
+// Requests joining a trace which wasn't started as an X-Ray trace, such as
+// a span started by a module configured with another propagator, are
+// propagated with an X-Ray compatible root trace ID derived from the trace
+// ID instead.
+//
+// The X-Ray format doesn't carry baggage, which is thus not propagated.
+type XRayPropagator struct{}
+
+// Propagate returns a header with the given span context in the X-Ray format
+func (p *XRayPropagator) Propagate(sc SpanContext) (http.Header, error) {
+	rootID, err := xrayRootID(sc.TraceID)
+	if err != nil {
+		return nil, err
+	}
+
+	sampledFlag := XRayUnsampledFlag
+	if sc.IsSampled() {
+		sampledFlag = XRaySampledFlag
+	}
+
+	header := http.Header{
+		XRayHeaderName: {"Root=" + rootID + ";Parent=" + sc.SpanID + ";Sampled=" + sampledFlag},
+	}
+
+	return header, nil
+}
+
+// newTraceID implements the traceIDGenerator interface.
+func (p *XRayPropagator) newTraceID() (string, error) {
+	return newXRayTraceID(time.Now())
+}
+
+// newXRayTraceID returns a new hex encoded trace ID, whose first 32 bits
+// hold the given start time in epoch seconds, as X-Ray expects.
+func newXRayTraceID(startTime time.Time) (string, error) {
+	random, err := randomHexString(12)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%08x", startTime.Unix()) + random, nil
+}
+
+// xrayRootID returns the X-Ray root trace ID of the given hex encoded
+// trace ID, formatted as 1-<8 hex digits epoch>-<24 hex digits id>.
+//
+// As the OpenTelemetry X-Ray propagator does, the epoch is read from the
+// trace ID's first 32 bits, so that the trace ID can be read back from the
+// X-Ray trace ID. When these bits don't hold a timestamp X-Ray accepts,
+// as is the case of the module's own trace IDs, the epoch is replaced by
+// the trace's start time, if the trace ID holds it, or the current time.
+func xrayRootID(traceID string) (string, error) {
+	buf, err := hex.DecodeString(traceID)
+	if err != nil || len(buf) != 16 {
+		return "", fmt.Errorf("invalid trace ID: %s", traceID)
+	}
+
+	now := time.Now()
+	if isXRayEpoch(int64(binary.BigEndian.Uint32(buf[:4])), now) {
+		return XRayTraceIDVersion + "-" + traceID[:8] + "-" + traceID[8:], nil
+	}
+
+	epoch := now.Unix()
+	if startTime, ok := k6TraceIDStartTime(buf); ok && isXRayEpoch(startTime.Unix(), now) {
+		epoch = startTime.Unix()
+	}
+
+	return XRayTraceIDVersion + "-" + fmt.Sprintf("%08x", epoch) + "-" + traceID[8:], nil
+}
+
+// isXRayEpoch returns true if the given epoch, in seconds, is a trace start
+// time X-Ray accepts at the given time.
+func isXRayEpoch(epoch int64, now time.Time) bool {
+	return epoch >= now.Add(-XRayMaxTraceAge).Unix() && epoch <= now.Add(XRayMaxClockSkew).Unix()
+}
+
+// k6TraceIDStartTime returns the start time encoded in the given k6 trace
+// ID, and whether the trace ID is a valid k6 trace ID.
+func k6TraceIDStartTime(buf []byte) (time.Time, bool) {
+	prefix, prefixLen := binary.Varint(buf)
+	if prefixLen <= 0 {
+		return time.Time{}, false
+	}
+
+	code, codeLen := binary.Varint(buf[prefixLen:])
+	if codeLen <= 0 {
+		return time.Time{}, false
+	}
+
+	timestamp, timestampLen := binary.Uvarint(buf[prefixLen+codeLen:])
+	if timestampLen <= 0 || timestamp > math.MaxInt64 {
+		return time.Time{}, false
+	}
+
+	traceID := TraceID{Prefix: int16(prefix), Code: int8(code), UnixTimestampNano: timestamp}
+	if !traceID.IsValid() {
+		return time.Time{}, false
+	}
+
+	// Despite its name, the trace ID's timestamp is in milliseconds.
+	return time.UnixMilli(int64(timestamp)), true
+}
@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/js/modulestest"
+)
+
+// xrayHeaderPattern matches X-Amzn-Trace-Id headers, as specified by the
+// AWS X-Ray documentation.
+var xrayHeaderPattern = regexp.MustCompile(
+	`^Root=1-([0-9a-f]{8})-([0-9a-f]{24});Parent=([0-9a-f]{16});Sampled=([01])$`,
+)
+
+func TestXRayPropagatorPropagate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sampled trace should follow the X-Ray header format", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&XRayPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, true))
+		require.NoError(t, err)
+
+		parts := xrayHeaderPattern.FindStringSubmatch(header.Get(XRayHeaderName))
+		require.Len(t, parts, 5)
+		assert.Equal(t, testTraceID[8:], parts[2])
+		assert.Equal(t, testSpanID, parts[3])
+		assert.Equal(t, XRaySampledFlag, parts[4])
+	})
+
+	t.Run("unsampled trace should unset the sampled flag", func(t *testing.T) {
+		t.Parallel()
+
+		header, err := (&XRayPropagator{}).Propagate(newTestSpanContext(testTraceID, testSpanID, false))
+		require.NoError(t, err)
+
+		parts := xrayHeaderPattern.FindStringSubmatch(header.Get(XRayHeaderName))
+		require.Len(t, parts, 5)
+		assert.Equal(t, XRayUnsampledFlag, parts[4])
+	})
+
+	t.Run("X-Ray trace IDs should embed their start time as epoch", func(t *testing.T) {
+		t.Parallel()
+
+		startTime := time.Now()
+		traceID, err := newXRayTraceID(startTime)
+		require.NoError(t, err)
+
+		header, err := (&XRayPropagator{}).Propagate(newTestSpanContext(traceID, testSpanID, true))
+		require.NoError(t, err)
+
+		parts := xrayHeaderPattern.FindStringSubmatch(header.Get(XRayHeaderName))
+		require.Len(t, parts, 5)
+
+		epoch, err := strconv.ParseInt(parts[1], 16, 64)
+		require.NoError(t, err)
+		assert.Equal(t, startTime.Unix(), epoch)
+	})
+
+	t.Run("other trace IDs should use their first 32 bits as epoch", func(t *testing.T) {
+		t.Parallel()
+
+		epoch := fmt.Sprintf("%08x", time.Now().Add(-time.Hour).Unix())
+		header, err := (&XRayPropagator{}).Propagate(newTestSpanContext(epoch+"bd862e3fe1be46a994272793", testSpanID, true))
+		require.NoError(t, err)
+
+		assert.Equal(t,
+			"Root=1-"+epoch+"-bd862e3fe1be46a994272793;Parent="+testSpanID+";Sampled=1",
+			header.Get(XRayHeaderName),
+		)
+	})
+
+	t.Run("k6 trace IDs should use their start time as epoch", func(t *testing.T) {
+		t.Parallel()
+
+		startTime := time.Now().Add(-time.Hour)
+		traceID, _, err := NewTraceID(k6Prefix, k6CloudCode, uint64(startTime.UnixMilli())).Encode()
+		require.NoError(t, err)
+
+		header, err := (&XRayPropagator{}).Propagate(newTestSpanContext(traceID, testSpanID, true))
+		require.NoError(t, err)
+
+		parts := xrayHeaderPattern.FindStringSubmatch(header.Get(XRayHeaderName))
+		require.Len(t, parts, 5)
+		assert.Equal(t, fmt.Sprintf("%08x", startTime.Unix()), parts[1])
+		assert.Equal(t, traceID[8:], parts[2])
+	})
+
+	t.Run("trace IDs without a plausible epoch should use the current time", func(t *testing.T) {
+		t.Parallel()
+
+		before := time.Now().Unix()
+		header, err := (&XRayPropagator{}).Propagate(newTestSpanContext("5759e988bd862e3fe1be46a994272793", testSpanID, true))
+		require.NoError(t, err)
+
+		parts := xrayHeaderPattern.FindStringSubmatch(header.Get(XRayHeaderName))
+		require.Len(t, parts, 5)
+		assert.Equal(t, "bd862e3fe1be46a994272793", parts[2])
+
+		epoch, err := strconv.ParseInt(parts[1], 16, 64)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, epoch, before)
+		assert.LessOrEqual(t, epoch, time.Now().Unix())
+	})
+
+	t.Run("invalid trace IDs should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := (&XRayPropagator{}).Propagate(newTestSpanContext("not-a-trace-id", testSpanID, true))
+
+		assert.Error(t, err)
+	})
+}
+
+func TestXRayTraceIDRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, propagator := range []interface{}{
+		XRayPropagatorName,
+		[]interface{}{W3CPropagatorName, XRayPropagatorName},
+	} {
+		propagator := propagator
+
+		t.Run(fmt.Sprint(propagator), func(t *testing.T) {
+			t.Parallel()
+
+			testSetup := modulestest.NewRuntime(t)
+			tracing := &Tracing{vu: testSetup.VU}
+			require.NoError(t, tracing.configure(instrumentationOptions{Propagator: propagator}))
+
+			before := time.Now().Unix()
+			trace, header, err := tracing.newRequestTrace()
+			require.NoError(t, err)
+
+			// The X-Ray trace ID holds the very trace ID recorded as
+			// metadata, and exported with the request's span.
+			parts := xrayHeaderPattern.FindStringSubmatch(header.Get(XRayHeaderName))
+			require.Len(t, parts, 5)
+			assert.Equal(t, trace.TraceID, parts[1]+parts[2])
+
+			epoch, err := strconv.ParseInt(parts[1], 16, 64)
+			require.NoError(t, err)
+			assert.GreaterOrEqual(t, epoch, before)
+			assert.LessOrEqual(t, epoch, time.Now().Unix())
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Sampler is an interface for deciding whether a trace should be sampled.
+type Sampler interface {
+	ShouldSample() bool
+}
+
+// DefaultSamplingRate is the sampling rate used when none is explicitly
+// configured. It defaults to sampling every trace.
+const DefaultSamplingRate = 1.0
+
+// ProbabilisticSampler is a Sampler that samples traces with a fixed
+// probability, independently for each request.
+type ProbabilisticSampler struct {
+	rate float64
+}
+
+// NewProbabilisticSampler returns a new ProbabilisticSampler sampling traces
+// at the given rate. The rate is expressed as a ratio, and must be contained
+// in the [0, 1] range, where 0 means that no trace is sampled, and 1 means
+// that every trace is sampled.
+func NewProbabilisticSampler(rate float64) (*ProbabilisticSampler, error) {
+	if math.IsNaN(rate) || rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("sampling rate must be between 0 and 1, got %v", rate)
+	}
+
+	return &ProbabilisticSampler{rate: rate}, nil
+}
+
+// ShouldSample returns true if the trace should be sampled.
+//
+// Note that this function uses a non-cryptographic random number generator.
+func (s *ProbabilisticSampler) ShouldSample() bool {
+	switch s.rate {
+	case 0:
+		return false
+	case 1:
+		return true
+	default:
+		return rand.Float64() < s.rate //nolint:gosec
+	}
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProbabilisticSampler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("rate within range should succeed", func(t *testing.T) {
+		t.Parallel()
+
+		for _, rate := range []float64{0, 0.25, 1} {
+			sampler, err := NewProbabilisticSampler(rate)
+
+			assert.NoError(t, err)
+			assert.NotNil(t, sampler)
+		}
+	})
+
+	t.Run("rate out of range or not a number should fail", func(t *testing.T) {
+		t.Parallel()
+
+		for _, rate := range []float64{-0.1, 1.1, 12, math.NaN(), math.Inf(1)} {
+			sampler, err := NewProbabilisticSampler(rate)
+
+			assert.Error(t, err)
+			assert.Nil(t, sampler)
+		}
+	})
+}
+
+func TestProbabilisticSamplerShouldSample(t *testing.T) {
+	t.Parallel()
+
+	t.Run("rate of 0 should never sample", func(t *testing.T) {
+		t.Parallel()
+
+		sampler, err := NewProbabilisticSampler(0)
+		require.NoError(t, err)
+
+		for i := 0; i < 1000; i++ {
+			assert.False(t, sampler.ShouldSample())
+		}
+	})
+
+	t.Run("rate of 1 should always sample", func(t *testing.T) {
+		t.Parallel()
+
+		sampler, err := NewProbabilisticSampler(1)
+		require.NoError(t, err)
+
+		for i := 0; i < 1000; i++ {
+			assert.True(t, sampler.ShouldSample())
+		}
+	})
+
+	t.Run("rate of 0.5 should sample roughly half of the traces", func(t *testing.T) {
+		t.Parallel()
+
+		sampler, err := NewProbabilisticSampler(0.5)
+		require.NoError(t, err)
+
+		sampled := 0
+		for i := 0; i < 10000; i++ {
+			if sampler.ShouldSample() {
+				sampled++
+			}
+		}
+
+		assert.InDelta(t, 5000, sampled, 500)
+	})
+}
@@ -0,0 +1,331 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// Exception span events name and attribute keys, as defined by the
+// OpenTelemetry semantic conventions.
+const (
+	exceptionEventName              = "exception"
+	exceptionTypeAttributeKey       = "exception.type"
+	exceptionMessageAttributeKey    = "exception.message"
+	exceptionStacktraceAttributeKey = "exception.stacktrace"
+	exceptionEscapedAttributeKey    = "exception.escaped"
+	defaultExceptionType            = "Error"
+)
+
+// Span status names, as set from the JS API.
+const (
+	spanStatusUnsetName = "unset"
+	spanStatusOKName    = "ok"
+	spanStatusErrorName = "error"
+)
+
+// spanOptions are the options that can be passed to the
+// tracing.startSpan() method.
+type spanOptions struct {
+	// Attributes are the initial attributes of the span.
+	Attributes map[string]interface{} `js:"attributes"`
+}
+
+// scriptSpan is a span started, and ended, from a k6 script.
+//
+// While a script span is active, that is started and not yet ended, the
+// HTTP requests made by the VU become its children. A span only remains
+// active until the end of the iteration it was started in.
+type scriptSpan struct {
+	// TraceID is the hex encoded ID of the trace the span belongs to.
+	TraceID string `js:"traceId"`
+
+	// SpanID is the hex encoded ID of the span.
+	SpanID string `js:"spanId"`
+
+	tracing *Tracing
+	span    *Span
+	sampled bool
+	ended   bool
+
+	// ctx is the context of the iteration the span was started in.
+	ctx context.Context
+}
+
+// StartSpan starts a new span, and makes it the VU's active span.
+//
+// If another span is active, the new span becomes its child. Otherwise,
+// the new span joins the trace of the current iteration, or starts a new
+// trace, depending on the configured trace scope.
+func (t *Tracing) StartSpan(name string, opts *spanOptions) *scriptSpan {
+	s, err := t.startSpan(name, opts)
+	if err != nil {
+		common.Throw(t.vu.Runtime(), err)
+	}
+
+	return s
+}
+
+// startSpan starts a new span, and makes it the VU's active span.
+func (t *Tracing) startSpan(name string, opts *spanOptions) (*scriptSpan, error) {
+	traceID, parentSpanID, sampled, err := t.traceContext()
+	if err != nil {
+		return nil, err
+	}
+
+	spanID, err := NewSpanID()
+	if err != nil {
+		return nil, err
+	}
+
+	span := &Span{
+		TraceID:      traceID,
+		SpanID:       spanID,
+		ParentSpanID: parentSpanID,
+		Name:         name,
+		Kind:         SpanKindInternal,
+		Attributes:   make(map[string]interface{}),
+	}
+
+	if opts != nil {
+		for key, value := range opts.Attributes {
+			span.Attributes[key] = value
+		}
+	}
+
+	s := &scriptSpan{
+		TraceID: span.TraceID,
+		SpanID:  span.SpanID,
+		tracing: t,
+		span:    span,
+		sampled: sampled,
+		ctx:     t.vu.Context(),
+	}
+
+	span.StartTime = time.Now()
+
+	module := t.module()
+	module.spans = append(module.spans, s)
+
+	return s, nil
+}
+
+// WithSpan runs a callback within a new active span, ending the span once
+// the callback returns or throws. It is called from JS as either
+// withSpan(name, fn), or withSpan(name, attributes, fn).
+//
+// The callback receives the span as argument, and its result is returned.
+// When the callback throws, the exception is recorded as a span event, the
+// span status is set to error, and the exception is thrown again.
+func (t *Tracing) WithSpan(call goja.FunctionCall) goja.Value {
+	rt := t.vu.Runtime()
+
+	name := call.Argument(0).String()
+
+	fnValue, attributes := call.Argument(1), call.Argument(2)
+	if _, ok := goja.AssertFunction(fnValue); !ok {
+		fnValue, attributes = call.Argument(2), call.Argument(1)
+	}
+
+	fn, ok := goja.AssertFunction(fnValue)
+	if !ok {
+		common.Throw(rt, errors.New("withSpan expects a function as last argument"))
+	}
+
+	opts := &spanOptions{}
+	if !isNullish(attributes) {
+		if err := rt.ExportTo(attributes, &opts.Attributes); err != nil {
+			common.Throw(rt, fmt.Errorf("invalid span attributes: %w", err))
+		}
+	}
+
+	result, err := t.runInSpan(name, opts, func(span *scriptSpan) (goja.Value, error) {
+		return fn(goja.Undefined(), rt.ToValue(span))
+	})
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	return result
+}
+
+// runInSpan runs the given function within a new active span, ending the
+// span once the function returns.
+//
+// When the function fails, its error is recorded on the span, and returned.
+func (t *Tracing) runInSpan(
+	name string, opts *spanOptions, fn func(span *scriptSpan) (goja.Value, error),
+) (goja.Value, error) {
+	span, err := t.startSpan(name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := fn(span)
+	if err != nil {
+		span.recordError(err)
+	}
+
+	span.End()
+
+	return result, err
+}
+
+// activeSpan returns the VU's active span, that is the last span started
+// in the current iteration that didn't end yet, if any.
+func (t *Tracing) activeSpan() *scriptSpan {
+	t.dropStaleSpans()
+
+	spans := t.module().spans
+	if len(spans) == 0 {
+		return nil
+	}
+
+	return spans[len(spans)-1]
+}
+
+// dropStaleSpans removes the spans started in an earlier iteration, and
+// never ended, from the VU's spans, so that they don't become the parent
+// of the spans and requests of the following iterations.
+//
+// Each VU iteration runs with its own context. As their end time is
+// unknown, the dropped spans aren't exported, unless the script ends
+// them later on.
+func (t *Tracing) dropStaleSpans() {
+	module, ctx := t.module(), t.vu.Context()
+
+	active := module.spans[:0]
+	for _, s := range module.spans {
+		if s.ctx == ctx {
+			active = append(active, s)
+			continue
+		}
+
+		t.logger().Warnf("span %q wasn't ended by the iteration it was started in, and is dropped", s.span.Name)
+	}
+
+	module.spans = active
+}
+
+// SetAttribute sets an attribute of the span.
+func (s *scriptSpan) SetAttribute(key string, value goja.Value) {
+	if s.ended {
+		return
+	}
+
+	s.span.Attributes[key] = value.Export()
+}
+
+// AddEvent records a time-stamped event on the span.
+func (s *scriptSpan) AddEvent(name string, attributes map[string]interface{}) {
+	if s.ended {
+		return
+	}
+
+	s.span.Events = append(s.span.Events, SpanEvent{
+		Name:       name,
+		Time:       time.Now(),
+		Attributes: attributes,
+	})
+}
+
+// SetStatus sets the status of the span, either unset, ok, or error,
+// along with an optional description.
+func (s *scriptSpan) SetStatus(code string, message string) {
+	status, err := parseSpanStatusCode(code)
+	if err != nil {
+		common.Throw(s.tracing.vu.Runtime(), err)
+	}
+
+	if s.ended {
+		return
+	}
+
+	s.span.Status = SpanStatus{Code: status, Message: message}
+}
+
+// End ends the span, and exports it if its trace is sampled.
+//
+// Ending a span that already ended has no effect.
+func (s *scriptSpan) End() {
+	if s.ended {
+		return
+	}
+
+	s.ended = true
+	s.span.EndTime = time.Now()
+
+	// Spans are usually ended in the reverse order they were started
+	// in, but are removed from the VU's spans wherever they stand.
+	module := s.tracing.module()
+	spans := module.spans
+	for i := len(spans) - 1; i >= 0; i-- {
+		if spans[i] == s {
+			module.spans = append(spans[:i], spans[i+1:]...)
+			break
+		}
+	}
+
+	if s.sampled {
+		s.tracing.exportSpans(s.span)
+	}
+}
+
+// recordError records an error that escaped the span as an exception
+// event, and sets the span status to error.
+func (s *scriptSpan) recordError(err error) {
+	if s.ended {
+		return
+	}
+
+	exceptionType, message := defaultExceptionType, err.Error()
+
+	attributes := map[string]interface{}{
+		exceptionEscapedAttributeKey: true,
+	}
+
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		if obj, ok := exception.Value().(*goja.Object); ok {
+			if name := obj.Get("name"); !isNullish(name) {
+				exceptionType = name.String()
+			}
+
+			if msg := obj.Get("message"); !isNullish(msg) {
+				message = msg.String()
+			}
+		} else {
+			message = exception.Value().String()
+		}
+
+		attributes[exceptionStacktraceAttributeKey] = exception.String()
+	}
+
+	attributes[exceptionTypeAttributeKey] = exceptionType
+	attributes[exceptionMessageAttributeKey] = message
+
+	s.span.Events = append(s.span.Events, SpanEvent{
+		Name:       exceptionEventName,
+		Time:       time.Now(),
+		Attributes: attributes,
+	})
+	s.span.Status = SpanStatus{Code: SpanStatusError, Message: message}
+}
+
+// parseSpanStatusCode returns the SpanStatusCode matching the given name.
+func parseSpanStatusCode(name string) (SpanStatusCode, error) {
+	switch name {
+	case spanStatusUnsetName:
+		return SpanStatusUnset, nil
+	case spanStatusOKName:
+		return SpanStatusOK, nil
+	case spanStatusErrorName:
+		return SpanStatusError, nil
+	default:
+		return SpanStatusUnset, fmt.Errorf("unknown span status: %s", name)
+	}
+}
@@ -0,0 +1,253 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/js/modulestest"
+)
+
+func TestTracingStartSpan(t *testing.T) {
+	t.Parallel()
+
+	t.Run("a span started without active span should start a new trace", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU, sampler: &ProbabilisticSampler{rate: 0}}
+
+		span, err := tracing.startSpan("journey", &spanOptions{Attributes: map[string]interface{}{"user": "bob"}})
+
+		require.NoError(t, err)
+		assert.Len(t, span.TraceID, 32)
+		assert.Len(t, span.SpanID, 16)
+		assert.Empty(t, span.span.ParentSpanID)
+		assert.Equal(t, SpanKindInternal, span.span.Kind)
+		assert.Equal(t, map[string]interface{}{"user": "bob"}, span.span.Attributes)
+		assert.False(t, span.sampled)
+		assert.Same(t, span, tracing.activeSpan())
+	})
+
+	t.Run("a span started with an active span should be its child", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU}
+
+		parent, err := tracing.startSpan("journey", nil)
+		require.NoError(t, err)
+		child, err := tracing.startSpan("login", nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, parent.TraceID, child.TraceID)
+		assert.Equal(t, parent.SpanID, child.span.ParentSpanID)
+		assert.Equal(t, parent.sampled, child.sampled)
+		assert.Same(t, child, tracing.activeSpan())
+	})
+
+	t.Run("requests made with an active span should be its children", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU, propagator: &W3CPropagator{}}
+
+		span, err := tracing.startSpan("journey", nil)
+		require.NoError(t, err)
+
+		headers := testSetup.VU.Runtime().NewObject()
+		trace, err := tracing.injectTraceHeaders(headers)
+
+		require.NoError(t, err)
+		assert.Equal(t, span.TraceID, trace.TraceID)
+		assert.Equal(t, span.SpanID, trace.ParentSpanID)
+		assert.NotEqual(t, span.SpanID, trace.SpanID)
+		assert.Equal(t, "00-"+span.TraceID+"-"+trace.SpanID+"-01", headers.Get(W3CHeaderName).String())
+	})
+
+	t.Run("spans left over by an earlier iteration should not remain active", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU}
+
+		stale, err := tracing.startSpan("journey", nil)
+		require.NoError(t, err)
+
+		// Each VU iteration runs with its own context.
+		testSetup.VU.CtxField = context.Background()
+
+		assert.Nil(t, tracing.activeSpan())
+		assert.Empty(t, tracing.spans)
+
+		span, err := tracing.startSpan("login", nil)
+		require.NoError(t, err)
+		assert.NotEqual(t, stale.TraceID, span.TraceID)
+		assert.Empty(t, span.span.ParentSpanID)
+	})
+}
+
+func TestScriptSpanEnd(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ending a span should restore its parent as active span", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU}
+
+		parent, err := tracing.startSpan("journey", nil)
+		require.NoError(t, err)
+		child, err := tracing.startSpan("login", nil)
+		require.NoError(t, err)
+
+		child.End()
+
+		assert.True(t, child.ended)
+		assert.False(t, child.span.EndTime.IsZero())
+		assert.Same(t, parent, tracing.activeSpan())
+	})
+
+	t.Run("ending a span out of order should leave the active span untouched", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU}
+
+		parent, err := tracing.startSpan("journey", nil)
+		require.NoError(t, err)
+		child, err := tracing.startSpan("login", nil)
+		require.NoError(t, err)
+
+		parent.End()
+		parent.End()
+
+		assert.Equal(t, []*scriptSpan{child}, tracing.spans)
+	})
+
+	t.Run("ended spans should not be modified", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU}
+
+		span, err := tracing.startSpan("journey", nil)
+		require.NoError(t, err)
+
+		span.AddEvent("clicked", nil)
+		span.SetStatus(spanStatusErrorName, "failed")
+		span.End()
+		span.AddEvent("ignored", nil)
+		span.SetStatus(spanStatusOKName, "")
+
+		require.Len(t, span.span.Events, 1)
+		assert.Equal(t, "clicked", span.span.Events[0].Name)
+		assert.Equal(t, SpanStatus{Code: SpanStatusError, Message: "failed"}, span.span.Status)
+	})
+}
+
+func TestParseSpanStatusCode(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]SpanStatusCode{
+		spanStatusUnsetName: SpanStatusUnset,
+		spanStatusOKName:    SpanStatusOK,
+		spanStatusErrorName: SpanStatusError,
+	} {
+		got, err := parseSpanStatusCode(name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := parseSpanStatusCode("failed")
+	assert.Error(t, err)
+}
+
+func TestTracingWithSpan(t *testing.T) {
+	t.Parallel()
+
+	newTestTracing := func(t *testing.T) (*Tracing, *goja.Runtime) {
+		t.Helper()
+
+		testSetup := modulestest.NewRuntime(t)
+		rt := testSetup.VU.Runtime()
+		rt.SetFieldNameMapper(goja.TagFieldNameMapper("js", true))
+
+		tracing := &Tracing{vu: testSetup.VU}
+		require.NoError(t, rt.Set("withSpan", tracing.WithSpan))
+
+		return tracing, rt
+	}
+
+	t.Run("the callback should run within an active span", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, rt := newTestTracing(t)
+		var active *scriptSpan
+		require.NoError(t, rt.Set("capture", func() { active = tracing.activeSpan() }))
+
+		got, err := rt.RunString(`withSpan("login", {user: "bob"}, (span) => { capture(); return span.spanId })`)
+
+		require.NoError(t, err)
+		require.NotNil(t, active)
+		assert.Equal(t, active.SpanID, got.String())
+		assert.Equal(t, map[string]interface{}{"user": "bob"}, active.span.Attributes)
+		assert.True(t, active.ended)
+		assert.Equal(t, SpanStatusUnset, active.span.Status.Code)
+		assert.Nil(t, tracing.activeSpan())
+	})
+
+	t.Run("attributes should be optional", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, rt := newTestTracing(t)
+
+		got, err := rt.RunString(`withSpan("login", () => 42)`)
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), got.Export())
+		assert.Nil(t, tracing.activeSpan())
+	})
+
+	t.Run("a thrown exception should be recorded and thrown again", func(t *testing.T) {
+		t.Parallel()
+
+		tracing, rt := newTestTracing(t)
+		var active *scriptSpan
+		require.NoError(t, rt.Set("capture", func() { active = tracing.activeSpan() }))
+
+		got, err := rt.RunString(`
+			let caught;
+			try {
+				withSpan("checkout", () => { capture(); throw new TypeError("cart is empty") });
+			} catch (e) {
+				caught = e;
+			}
+			caught instanceof TypeError && caught.message
+		`)
+
+		require.NoError(t, err)
+		assert.Equal(t, "cart is empty", got.String())
+		require.NotNil(t, active)
+		assert.True(t, active.ended)
+		assert.Equal(t, SpanStatus{Code: SpanStatusError, Message: "cart is empty"}, active.span.Status)
+		require.Len(t, active.span.Events, 1)
+		assert.Equal(t, exceptionEventName, active.span.Events[0].Name)
+		assert.Equal(t, "TypeError", active.span.Events[0].Attributes[exceptionTypeAttributeKey])
+		assert.Equal(t, "cart is empty", active.span.Events[0].Attributes[exceptionMessageAttributeKey])
+		assert.Contains(t, active.span.Events[0].Attributes[exceptionStacktraceAttributeKey], "cart is empty")
+		assert.Nil(t, tracing.activeSpan())
+	})
+
+	t.Run("a missing callback should throw", func(t *testing.T) {
+		t.Parallel()
+
+		_, rt := newTestTracing(t)
+
+		_, err := rt.RunString(`withSpan("login", {user: "bob"})`)
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"time"
+)
+
+// Span represents a single timed operation, as part of a trace.
+type Span struct {
+	// TraceID is the hex encoded ID of the trace the span belongs to.
+	TraceID string
+
+	// SpanID is the hex encoded ID of the span.
+	SpanID string
+
+	// ParentSpanID is the hex encoded ID of the span's parent, if any.
+	ParentSpanID string
+
+	// Name describes the operation the span represents.
+	Name string
+
+	// Kind describes the relationship between the span and its
+	// remote parent or children.
+	Kind SpanKind
+
+	// StartTime is the time at which the operation started.
+	StartTime time.Time
+
+	// EndTime is the time at which the operation ended.
+	EndTime time.Time
+
+	// Attributes hold the key-value pairs describing the operation.
+	Attributes map[string]interface{}
+
+	// Events are the time-stamped annotations of the span.
+	Events []SpanEvent
+
+	// Status is the outcome of the operation.
+	Status SpanStatus
+}
+
+// SpanKind describes the relationship between a span and its remote
+// parent or children.
+type SpanKind int
+
+// SpanKind constants, matching the OpenTelemetry span kinds.
+const (
+	SpanKindUnspecified SpanKind = iota
+	SpanKindInternal
+	SpanKindServer
+	SpanKindClient
+	SpanKindProducer
+	SpanKindConsumer
+)
+
+// SpanEvent is a time-stamped annotation of a span.
+type SpanEvent struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]interface{}
+}
+
+// SpanStatus is the outcome of the operation a span represents.
+type SpanStatus struct {
+	Code    SpanStatusCode
+	Message string
+}
+
+// SpanStatusCode is the status code of a span.
+type SpanStatusCode int
+
+// SpanStatusCode constants, matching the OpenTelemetry status codes.
+const (
+	SpanStatusUnset SpanStatusCode = iota
+	SpanStatusOK
+	SpanStatusError
+)
+
+// NewSpanID returns a new random, hex encoded, 8 bytes span ID.
+func NewSpanID() (string, error) {
+	return randomHexString(8)
+}
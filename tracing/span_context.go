@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SpanContext is the context of a span, as propagated to the services a
+// request is sent to, so that their spans join the span's trace as its
+// children.
+//
+// The span context of a request is generated once, and passed to each of
+// the configured propagators, so that the trace and span IDs they inject
+// are those the module records in its spans and metrics metadata.
+type SpanContext struct {
+	// TraceID is the hex encoded ID of the trace the span belongs to.
+	TraceID string
+
+	// SpanID is the hex encoded ID of the span.
+	SpanID string
+
+	// ParentSpanID is the hex encoded ID of the span's parent, if any.
+	ParentSpanID string
+
+	// TraceFlags are the W3C trace flags of the span.
+	TraceFlags TraceFlags
+
+	// TraceState is the W3C trace state of the span, if any, holding
+	// vendor-specific trace identification data, as a tracestate header
+	// value.
+	TraceState string
+
+	// Baggage is the baggage propagated alongside the span context.
+	Baggage Baggage
+}
+
+// IsSampled returns true if the span's trace is sampled.
+func (sc SpanContext) IsSampled() bool {
+	return sc.TraceFlags.IsSampled()
+}
+
+// TraceFlags is the bitmap of W3C trace flags.
+type TraceFlags byte
+
+// TraceFlagsSampled is the trace flag set on sampled traces.
+const TraceFlagsSampled TraceFlags = 0x01
+
+// IsSampled returns true if the sampled flag is set.
+func (f TraceFlags) IsSampled() bool {
+	return f&TraceFlagsSampled == TraceFlagsSampled
+}
+
+// newTraceFlags returns the trace flags of a trace, given whether it is sampled.
+func newTraceFlags(sampled bool) TraceFlags {
+	if sampled {
+		return TraceFlagsSampled
+	}
+
+	return 0
+}
+
+// TraceStateMaxMembers is the maximum number of list-members a W3C trace
+// state is allowed to hold.
+const TraceStateMaxMembers = 32
+
+var (
+	// traceStateKeyRegexp matches the keys of W3C trace state list-members,
+	// either simple or multi-tenant keys.
+	traceStateKeyRegexp = regexp.MustCompile( //nolint:gochecknoglobals
+		`^([a-z0-9][_0-9a-z\-*/]{0,255}|[a-z0-9][_0-9a-z\-*/]{0,240}@[a-z][_0-9a-z\-*/]{0,13})$`,
+	)
+
+	// traceStateValueRegexp matches the values of W3C trace state list-members.
+	traceStateValueRegexp = regexp.MustCompile( //nolint:gochecknoglobals
+		`^[\x20-\x2b\x2d-\x3c\x3e-\x7e]{0,255}[\x21-\x2b\x2d-\x3c\x3e-\x7e]$`,
+	)
+)
+
+// NewTraceState validates the traceState option, as it was passed by the
+// user, against the W3C trace context specification, and returns it in the
+// tracestate header format, such as:
+//
+//	vendor1=value1,vendor2=value2
+//
+// Empty list-members and the whitespace surrounding list-members are dropped.
+func NewTraceState(value string) (string, error) {
+	members := make([]string, 0, strings.Count(value, ",")+1)
+	keys := make(map[string]bool)
+
+	for _, member := range strings.Split(value, ",") {
+		member = strings.Trim(member, " \t")
+		if member == "" {
+			continue
+		}
+
+		key, memberValue, ok := strings.Cut(member, "=")
+		if !ok || !traceStateKeyRegexp.MatchString(key) {
+			return "", fmt.Errorf("trace state member %q doesn't hold a valid key", member)
+		}
+
+		if !traceStateValueRegexp.MatchString(memberValue) {
+			return "", fmt.Errorf("trace state member %q doesn't hold a valid value", member)
+		}
+
+		if keys[key] {
+			return "", fmt.Errorf("trace state key %q is duplicated", key)
+		}
+		keys[key] = true
+
+		members = append(members, member)
+	}
+
+	if len(members) > TraceStateMaxMembers {
+		return "", fmt.Errorf(
+			"trace state holds %d members, exceeding the maximum of %d", len(members), TraceStateMaxMembers,
+		)
+	}
+
+	return strings.Join(members, ","), nil
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpanContextIsSampled(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, SpanContext{TraceFlags: newTraceFlags(true)}.IsSampled())
+	assert.False(t, SpanContext{TraceFlags: newTraceFlags(false)}.IsSampled())
+	assert.True(t, SpanContext{TraceFlags: TraceFlags(0x03)}.IsSampled(), "other flags should be ignored")
+	assert.False(t, SpanContext{}.IsSampled())
+}
+
+func TestNewTraceState(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid members should be kept in order", func(t *testing.T) {
+		t.Parallel()
+
+		traceState, err := NewTraceState("k6=1, tenant@vendor=a b ,, other=x")
+
+		require.NoError(t, err)
+		assert.Equal(t, "k6=1,tenant@vendor=a b,other=x", traceState)
+	})
+
+	t.Run("empty trace state should stay empty", func(t *testing.T) {
+		t.Parallel()
+
+		traceState, err := NewTraceState("")
+
+		require.NoError(t, err)
+		assert.Empty(t, traceState)
+	})
+
+	t.Run("invalid members should fail", func(t *testing.T) {
+		t.Parallel()
+
+		for _, value := range []string{"k6", "K6=1", "k6=", "k6=a=b", "k6=1,k6=2"} {
+			_, err := NewTraceState(value)
+			assert.Error(t, err, value)
+		}
+	})
+
+	t.Run("too many members should fail", func(t *testing.T) {
+		t.Parallel()
+
+		members := make([]string, 0, TraceStateMaxMembers+1)
+		for i := 0; i <= TraceStateMaxMembers; i++ {
+			members = append(members, fmt.Sprintf("key%d=value", i))
+		}
+
+		_, err := NewTraceState(strings.Join(members, ","))
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,438 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/metrics"
+)
+
+const (
+	// DefaultSpanQueueSize is the maximum number of spans waiting to be
+	// exported, unless explicitly configured otherwise.
+	DefaultSpanQueueSize = 2048
+
+	// DefaultSpanBatchSize is the maximum number of spans exported at
+	// once, unless explicitly configured otherwise.
+	DefaultSpanBatchSize = 512
+
+	// DefaultSpanFlushInterval is the maximum duration spans wait in the
+	// queue before being exported, unless explicitly configured otherwise.
+	DefaultSpanFlushInterval = 5 * time.Second
+
+	// spanExportTimeout is the maximum duration of a single batch export.
+	spanExportTimeout = 30 * time.Second
+)
+
+// Span processor metrics names.
+const (
+	spansExportedMetricName = "tracing_spans_exported"
+	spansDroppedMetricName  = "tracing_spans_dropped"
+	spansFailedMetricName   = "tracing_spans_failed"
+)
+
+// batchOptions are the options that can be passed to the exporter
+// option's batch option, tuning how spans are batched before export.
+type batchOptions struct {
+	// QueueSize is the maximum number of spans waiting to be exported.
+	// Spans recorded while the queue is full are dropped.
+	QueueSize int `js:"queueSize"`
+
+	// MaxBatchSize is the maximum number of spans exported at once.
+	MaxBatchSize int `js:"maxBatchSize"`
+
+	// FlushInterval is the maximum duration spans wait in the queue
+	// before being exported, expressed as a duration string, such as 5s.
+	FlushInterval string `js:"flushInterval"`
+}
+
+// spanProcessorMetrics are the k6 metrics reporting the spans
+// handled by the span processors.
+type spanProcessorMetrics struct {
+	// Exported counts the spans successfully exported.
+	Exported *metrics.Metric
+
+	// Dropped counts the spans dropped because the queue was full.
+	Dropped *metrics.Metric
+
+	// Failed counts the spans whose export failed.
+	Failed *metrics.Metric
+}
+
+// registerSpanProcessorMetrics registers the span processor metrics
+// in the given registry.
+func registerSpanProcessorMetrics(registry *metrics.Registry) (*spanProcessorMetrics, error) {
+	exported, err := registry.NewMetric(spansExportedMetricName, metrics.Counter)
+	if err != nil {
+		return nil, err
+	}
+
+	dropped, err := registry.NewMetric(spansDroppedMetricName, metrics.Counter)
+	if err != nil {
+		return nil, err
+	}
+
+	failed, err := registry.NewMetric(spansFailedMetricName, metrics.Counter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &spanProcessorMetrics{Exported: exported, Dropped: dropped, Failed: failed}, nil
+}
+
+// batchSpanProcessor queues the spans recorded by the VUs, and exports
+// them in batches from a background goroutine, so that VUs never wait on
+// the exporter.
+//
+// When the queue is full, recorded spans are dropped rather than blocking
+// the VU recording them. Batches are exported whenever they reach the
+// maximum batch size, and when the flush interval elapses. The remaining
+// spans are exported when the processor is shut down, at the end of the
+// test.
+//
+// The background goroutine is only started once spans are queued, so that
+// the processors of scripts which are only initialized, such as by k6
+// inspect or k6 archive, never need to be shut down.
+type batchSpanProcessor struct {
+	exporter      SpanExporter
+	maxBatchSize  int
+	flushInterval time.Duration
+
+	queue         chan *Span
+	flushRequests chan chan struct{}
+	stopRequests  chan chan struct{}
+
+	// started ensures the background goroutine is started at most once,
+	// and never once the processor is shut down.
+	started sync.Once
+
+	// stopped is closed once the background goroutine returned, or once
+	// the processor is shut down if it never started.
+	stopped chan struct{}
+
+	// exported, failed, and dropped count the spans exported, whose
+	// export failed, and dropped, since the metrics were last pushed.
+	exported uint64
+	failed   uint64
+	dropped  uint64
+
+	// metrics are the metrics reporting the handled spans, under tags.
+	metrics *spanProcessorMetrics
+	tags    *metrics.TagSet
+	logger  logrus.FieldLogger
+
+	// mu protects the fields below.
+	mu sync.Mutex
+
+	// iterations holds the contexts of the running VU iterations that
+	// recorded spans, along with the callbacks to call once they're done.
+	iterations map[context.Context][]func()
+}
+
+// newBatchSpanProcessor returns a new batchSpanProcessor exporting spans
+// with the given exporter.
+//
+// The processor metrics are reported under the given tags.
+func newBatchSpanProcessor(
+	exporter SpanExporter,
+	opts *batchOptions,
+	m *spanProcessorMetrics,
+	tags *metrics.TagSet,
+	logger logrus.FieldLogger,
+) (*batchSpanProcessor, error) {
+	queueSize, maxBatchSize, flushInterval := DefaultSpanQueueSize, DefaultSpanBatchSize, DefaultSpanFlushInterval
+
+	if opts != nil {
+		if opts.QueueSize < 0 || opts.MaxBatchSize < 0 {
+			return nil, errors.New("batch queueSize and maxBatchSize must be positive")
+		}
+
+		if opts.QueueSize > 0 {
+			queueSize = opts.QueueSize
+		}
+
+		if opts.MaxBatchSize > 0 {
+			maxBatchSize = opts.MaxBatchSize
+		}
+
+		if opts.FlushInterval != "" {
+			interval, err := time.ParseDuration(opts.FlushInterval)
+			if err != nil || interval <= 0 {
+				return nil, fmt.Errorf("invalid batch flushInterval %q, expected a positive duration", opts.FlushInterval)
+			}
+
+			flushInterval = interval
+		}
+	}
+
+	if maxBatchSize > queueSize {
+		maxBatchSize = queueSize
+	}
+
+	p := &batchSpanProcessor{
+		exporter:      exporter,
+		maxBatchSize:  maxBatchSize,
+		flushInterval: flushInterval,
+		queue:         make(chan *Span, queueSize),
+		flushRequests: make(chan chan struct{}),
+		stopRequests:  make(chan chan struct{}),
+		stopped:       make(chan struct{}),
+		metrics:       m,
+		tags:          tags,
+		logger:        logger,
+		iterations:    make(map[context.Context][]func()),
+	}
+
+	return p, nil
+}
+
+// OnEnd queues the given spans, recorded by the given VU, for export.
+//
+// It never blocks on the exporter: spans recorded while the queue is full
+// are dropped. It also pushes the processor metrics through the VU.
+func (p *batchSpanProcessor) OnEnd(vu modules.VU, spans ...*Span) {
+	p.enqueue(spans...)
+
+	if state := vu.State(); state != nil {
+		p.pushMetrics(vu.Context(), state.Samples)
+	}
+}
+
+// ForceFlush exports all the queued spans, and returns once done.
+//
+// It returns right away once the processor is shut down.
+func (p *batchSpanProcessor) ForceFlush() {
+	p.start()
+
+	done := make(chan struct{})
+
+	select {
+	case p.flushRequests <- done:
+		<-done
+	case <-p.stopped:
+	}
+}
+
+// Shutdown ends the spans covering the iterations still watched, exports
+// all the queued spans, stops the background goroutine, and shuts the
+// exporter down.
+//
+// The final processor metrics are pushed to the given samples channel.
+// As k6 closes it once done with the test run, the caller passes a nil
+// channel when it can't tell whether k6 still reads it, in which case the
+// spans that were dropped or whose export failed are logged instead.
+//
+// It is meant to be called once the test has ended: the spans recorded
+// past this point are never exported. Calling it again has no effect.
+func (p *batchSpanProcessor) Shutdown(ctx context.Context, samples chan<- metrics.SampleContainer) error {
+	p.mu.Lock()
+	for ctx, callbacks := range p.iterations {
+		for _, callback := range callbacks {
+			callback()
+		}
+
+		delete(p.iterations, ctx)
+	}
+	p.mu.Unlock()
+
+	// A processor which never started has nothing to export.
+	p.started.Do(func() { close(p.stopped) })
+
+	done := make(chan struct{})
+
+	select {
+	case p.stopRequests <- done:
+		<-done
+	case <-p.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if samples != nil {
+		p.pushMetrics(ctx, samples)
+	}
+
+	// The counts which couldn't be pushed are reported in the logs.
+	if dropped := atomic.SwapUint64(&p.dropped, 0); dropped > 0 {
+		p.logger.Warnf("%d spans were dropped, as the span queue was full", dropped)
+	}
+
+	if failed := atomic.SwapUint64(&p.failed, 0); failed > 0 {
+		p.logger.Warnf("%d spans failed to be exported", failed)
+	}
+
+	return p.exporter.Shutdown(ctx)
+}
+
+// start starts the background goroutine, unless already done, or unless
+// the processor is shut down.
+func (p *batchSpanProcessor) start() {
+	p.started.Do(func() { go p.run() })
+}
+
+// watchIteration calls the given callbacks once the given VU iteration
+// context is done, letting callers record spans covering the whole
+// iteration.
+//
+// The callbacks of the iterations still running when the processor is
+// shut down are called by Shutdown.
+func (p *batchSpanProcessor) watchIteration(ctx context.Context, onDone ...func()) {
+	if ctx == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	callbacks, watched := p.iterations[ctx]
+	p.iterations[ctx] = append(callbacks, onDone...)
+
+	if watched {
+		return
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		// The callbacks are called while holding the lock, so that
+		// Shutdown never misses the spans they record.
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		for _, callback := range p.iterations[ctx] {
+			callback()
+		}
+
+		delete(p.iterations, ctx)
+	}()
+}
+
+// enqueue queues the given spans for export, dropping them if
+// the queue is full.
+func (p *batchSpanProcessor) enqueue(spans ...*Span) {
+	p.start()
+
+	for _, span := range spans {
+		select {
+		case p.queue <- span:
+		default:
+			atomic.AddUint64(&p.dropped, 1)
+		}
+	}
+}
+
+// run consumes the queue, and exports its spans in batches, until the
+// processor is shut down.
+func (p *batchSpanProcessor) run() {
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, p.maxBatchSize)
+
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) >= p.maxBatchSize {
+				batch = p.export(batch)
+			}
+		case <-ticker.C:
+			batch = p.export(batch)
+		case done := <-p.flushRequests:
+			batch = p.drain(batch)
+			batch = p.export(batch)
+			close(done)
+		case done := <-p.stopRequests:
+			p.export(p.drain(batch))
+			close(p.stopped)
+			close(done)
+
+			return
+		}
+	}
+}
+
+// drain moves all the queued spans to the given batch, exporting it
+// whenever it reaches the maximum batch size.
+func (p *batchSpanProcessor) drain(batch []*Span) []*Span {
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) >= p.maxBatchSize {
+				batch = p.export(batch)
+			}
+		default:
+			return batch
+		}
+	}
+}
+
+// export exports the given batch, counts the outcome for the processor
+// metrics, and returns the emptied batch.
+func (p *batchSpanProcessor) export(batch []*Span) []*Span {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), spanExportTimeout)
+	defer cancel()
+
+	if err := p.exporter.ExportSpans(ctx, batch); err != nil {
+		p.logger.WithError(err).Warn("Failed to export spans")
+		atomic.AddUint64(&p.failed, uint64(len(batch)))
+	} else {
+		atomic.AddUint64(&p.exported, uint64(len(batch)))
+	}
+
+	return batch[:0]
+}
+
+// pushMetrics pushes the processor metrics counted since they were last
+// pushed, to the given samples channel, unless the given context is done.
+//
+// As k6 closes the samples channel once done with the test run, the metrics
+// are only ever pushed by running VUs, or at shutdown, rather than by the
+// background goroutine. Counts that couldn't be pushed are left for a later
+// push.
+func (p *batchSpanProcessor) pushMetrics(ctx context.Context, samples chan<- metrics.SampleContainer) {
+	counters := []struct {
+		metric *metrics.Metric
+		count  *uint64
+	}{
+		{p.metrics.Exported, &p.exported},
+		{p.metrics.Failed, &p.failed},
+		{p.metrics.Dropped, &p.dropped},
+	}
+
+	now := time.Now()
+	counts := make([]uint64, len(counters))
+	pushed := make(metrics.Samples, 0, len(counters))
+	for i, counter := range counters {
+		counts[i] = atomic.SwapUint64(counter.count, 0)
+		if counts[i] == 0 {
+			continue
+		}
+
+		pushed = append(pushed, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: counter.metric, Tags: p.tags},
+			Time:       now,
+			Value:      float64(counts[i]),
+		})
+	}
+
+	if len(pushed) == 0 || metrics.PushIfNotDone(ctx, samples, pushed) {
+		return
+	}
+
+	for i, counter := range counters {
+		atomic.AddUint64(counter.count, counts[i])
+	}
+}
@@ -0,0 +1,383 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+	"gopkg.in/guregu/null.v3"
+)
+
+// recordingExporter is a SpanExporter recording the batches it exports.
+type recordingExporter struct {
+	mu      sync.Mutex
+	batches [][]*Span
+
+	// err is returned by every export, if set.
+	err error
+
+	// unblock, if set, blocks every export until it is closed.
+	unblock chan struct{}
+
+	// shutdown counts the calls to Shutdown.
+	shutdown int
+}
+
+func (e *recordingExporter) ExportSpans(_ context.Context, spans []*Span) error {
+	if e.unblock != nil {
+		<-e.unblock
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.batches = append(e.batches, append([]*Span(nil), spans...))
+
+	return e.err
+}
+
+func (e *recordingExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.shutdown++
+
+	return nil
+}
+
+func (e *recordingExporter) batchSizes() []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	sizes := make([]int, 0, len(e.batches))
+	for _, batch := range e.batches {
+		sizes = append(sizes, len(batch))
+	}
+
+	return sizes
+}
+
+// newTestSpanProcessor returns a batchSpanProcessor exporting to the given
+// exporter, along with a VU recording spans, and the channel the processor
+// metrics samples are pushed to.
+func newTestSpanProcessor(
+	t *testing.T, exporter SpanExporter, opts *batchOptions,
+) (*batchSpanProcessor, *modulestest.Runtime, chan metrics.SampleContainer) {
+	t.Helper()
+
+	testSetup := modulestest.NewRuntime(t)
+	registry := testSetup.VU.InitEnv().Registry
+
+	m, err := registerSpanProcessorMetrics(registry)
+	require.NoError(t, err)
+
+	processor, err := newBatchSpanProcessor(exporter, opts, m, registry.RootTagSet(), logrus.New())
+	require.NoError(t, err)
+
+	samples := make(chan metrics.SampleContainer, 100)
+	testSetup.MoveToVUContext(&lib.State{Samples: samples})
+
+	return processor, testSetup, samples
+}
+
+// sumSamples returns the sum of the values of the pushed
+// samples of the metric with the given name.
+func sumSamples(samples chan metrics.SampleContainer, name string) float64 {
+	var sum float64
+	for {
+		select {
+		case container := <-samples:
+			for _, sample := range container.GetSamples() {
+				if sample.Metric.Name == name {
+					sum += sample.Value
+				}
+			}
+		default:
+			return sum
+		}
+	}
+}
+
+func TestBatchSpanProcessor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("spans should be exported in batches of at most the max batch size", func(t *testing.T) {
+		t.Parallel()
+
+		exporter := &recordingExporter{}
+		processor, testSetup, samples := newTestSpanProcessor(t, exporter, &batchOptions{MaxBatchSize: 2, FlushInterval: "1h"})
+
+		for i := 0; i < 5; i++ {
+			processor.OnEnd(testSetup.VU, &Span{TraceID: testTraceID, SpanID: testSpanID})
+		}
+		processor.ForceFlush()
+		processor.pushMetrics(testSetup.VU.Context(), samples)
+
+		assert.Equal(t, []int{2, 2, 1}, exporter.batchSizes())
+		assert.Equal(t, float64(5), sumSamples(samples, spansExportedMetricName))
+	})
+
+	t.Run("spans should be exported once the flush interval elapses", func(t *testing.T) {
+		t.Parallel()
+
+		exporter := &recordingExporter{}
+		processor, testSetup, _ := newTestSpanProcessor(t, exporter, &batchOptions{FlushInterval: "10ms"})
+
+		processor.OnEnd(testSetup.VU, &Span{TraceID: testTraceID, SpanID: testSpanID})
+
+		assert.Eventually(t, func() bool {
+			return len(exporter.batchSizes()) == 1
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("spans should be exported on shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		exporter := &recordingExporter{}
+		processor, testSetup, _ := newTestSpanProcessor(t, exporter, &batchOptions{FlushInterval: "1h"})
+
+		processor.OnEnd(testSetup.VU, &Span{TraceID: testTraceID, SpanID: testSpanID})
+		require.NoError(t, processor.Shutdown(context.Background(), nil))
+
+		assert.Equal(t, []int{1}, exporter.batchSizes())
+		assert.Equal(t, 1, exporter.shutdown)
+
+		// Shutting down again, or flushing, once shut down has no effect.
+		require.NoError(t, processor.Shutdown(context.Background(), nil))
+		processor.ForceFlush()
+		assert.Equal(t, 1, exporter.shutdown)
+	})
+
+	t.Run("the final metrics should be pushed on shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		exporter := &recordingExporter{}
+		processor, testSetup, samples := newTestSpanProcessor(t, exporter, &batchOptions{FlushInterval: "1h"})
+
+		processor.OnEnd(testSetup.VU, &Span{TraceID: testTraceID, SpanID: testSpanID})
+		require.NoError(t, processor.Shutdown(context.Background(), samples))
+
+		assert.Equal(t, float64(1), sumSamples(samples, spansExportedMetricName))
+	})
+
+	t.Run("processors which never queued spans should not start", func(t *testing.T) {
+		t.Parallel()
+
+		exporter := &recordingExporter{}
+		processor, _, _ := newTestSpanProcessor(t, exporter, nil)
+
+		require.NoError(t, processor.Shutdown(context.Background(), nil))
+
+		// Spans queued once shut down are never exported.
+		processor.enqueue(&Span{TraceID: testTraceID, SpanID: testSpanID})
+		processor.ForceFlush()
+		assert.Empty(t, exporter.batchSizes())
+		assert.Equal(t, 0, exporter.shutdown)
+	})
+
+	t.Run("recorded spans should not watch their iteration", func(t *testing.T) {
+		t.Parallel()
+
+		processor, testSetup, _ := newTestSpanProcessor(t, &recordingExporter{}, nil)
+
+		processor.OnEnd(testSetup.VU, &Span{TraceID: testTraceID, SpanID: testSpanID})
+
+		processor.mu.Lock()
+		defer processor.mu.Unlock()
+		assert.Empty(t, processor.iterations)
+	})
+
+	t.Run("spans of the running iterations should be ended on shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		exporter := &recordingExporter{}
+		processor, testSetup, _ := newTestSpanProcessor(t, exporter, &batchOptions{FlushInterval: "1h"})
+
+		span := &Span{TraceID: testTraceID, SpanID: testSpanID}
+		processor.watchIteration(testSetup.VU.Context(), func() { processor.enqueue(span) })
+		require.NoError(t, processor.Shutdown(context.Background(), nil))
+
+		assert.Equal(t, []int{1}, exporter.batchSizes())
+	})
+
+	t.Run("spans should be dropped when the queue is full", func(t *testing.T) {
+		t.Parallel()
+
+		exporter := &recordingExporter{unblock: make(chan struct{})}
+		processor, testSetup, samples := newTestSpanProcessor(t, exporter, &batchOptions{
+			QueueSize:     2,
+			MaxBatchSize:  1,
+			FlushInterval: "1h",
+		})
+
+		// The first span is consumed right away, and blocks the processor
+		// in its export.
+		processor.OnEnd(testSetup.VU, &Span{TraceID: testTraceID, SpanID: testSpanID})
+		assert.Eventually(t, func() bool { return len(processor.queue) == 0 }, time.Second, time.Millisecond)
+
+		done := make(chan struct{})
+		go func() {
+			processor.OnEnd(testSetup.VU, &Span{}, &Span{}, &Span{}, &Span{})
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("recording spans blocked on a full queue")
+		}
+
+		close(exporter.unblock)
+		processor.ForceFlush()
+		processor.pushMetrics(testSetup.VU.Context(), samples)
+
+		assert.Equal(t, []int{1, 1, 1}, exporter.batchSizes())
+		assert.Equal(t, float64(2), sumSamples(samples, spansDroppedMetricName))
+	})
+
+	t.Run("failed exports should be counted", func(t *testing.T) {
+		t.Parallel()
+
+		exporter := &recordingExporter{err: errors.New("collector unavailable")}
+		processor, testSetup, samples := newTestSpanProcessor(t, exporter, nil)
+
+		processor.OnEnd(testSetup.VU, &Span{}, &Span{})
+		processor.ForceFlush()
+		processor.pushMetrics(testSetup.VU.Context(), samples)
+
+		assert.Equal(t, float64(2), sumSamples(samples, spansFailedMetricName))
+	})
+
+	t.Run("invalid options should fail", func(t *testing.T) {
+		t.Parallel()
+
+		for _, opts := range []*batchOptions{
+			{QueueSize: -1},
+			{MaxBatchSize: -1},
+			{FlushInterval: "soon"},
+			{FlushInterval: "-1s"},
+		} {
+			_, err := newBatchSpanProcessor(&recordingExporter{}, opts, nil, nil, logrus.New())
+			assert.Error(t, err)
+		}
+	})
+}
+
+func TestRootModuleSpanProcessor(t *testing.T) {
+	t.Parallel()
+
+	testSetup := modulestest.NewRuntime(t)
+	root := &RootModule{}
+	require.NoError(t, root.registerMetrics(testSetup.VU.InitEnv().Registry))
+
+	opts := exporterOptions{Protocol: FileExporterProtocol, Path: t.TempDir() + "/spans.jsonl"}
+
+	first, err := root.spanProcessor(opts, logrus.New())
+	require.NoError(t, err)
+	second, err := root.spanProcessor(opts, logrus.New())
+	require.NoError(t, err)
+
+	opts.ServiceName = "other"
+	other, err := root.spanProcessor(opts, logrus.New())
+	require.NoError(t, err)
+
+	assert.Same(t, first, second)
+	assert.NotSame(t, first, other)
+}
+
+func TestRootModuleShutdown(t *testing.T) {
+	t.Parallel()
+
+	// newTestRootModule returns a root module with a processor, along with
+	// a tracing instance recording spans in a test run with the given
+	// runtime options, the run's execution state, and the channel its
+	// metrics samples are pushed to.
+	newTestRootModule := func(t *testing.T, runtimeOptions lib.RuntimeOptions) (
+		*RootModule, *Tracing, *lib.ExecutionState, chan metrics.SampleContainer,
+	) {
+		t.Helper()
+
+		testSetup := modulestest.NewRuntime(t)
+		root := New()
+		root.NewModuleInstance(testSetup.VU)
+
+		opts := exporterOptions{Protocol: FileExporterProtocol, Path: t.TempDir() + "/spans.jsonl"}
+		processor, err := root.spanProcessor(opts, logrus.New())
+		require.NoError(t, err)
+
+		et, err := lib.NewExecutionTuple(nil, nil)
+		require.NoError(t, err)
+		execution := lib.NewExecutionState(&lib.TestRunState{
+			TestPreInitState: &lib.TestPreInitState{RuntimeOptions: runtimeOptions},
+		}, et, 1, 1)
+
+		samples := make(chan metrics.SampleContainer, 100)
+		testSetup.MoveToVUContext(&lib.State{Samples: samples, Logger: logrus.New()})
+		testSetup.VU.CtxField = lib.WithExecutionState(testSetup.VU.Context(), execution)
+
+		return root, &Tracing{vu: testSetup.VU, root: root, processor: processor}, execution, samples
+	}
+
+	t.Run("the output should shut the processors down", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		root := &RootModule{}
+		require.NoError(t, root.registerMetrics(testSetup.VU.InitEnv().Registry))
+
+		_, err := root.NewOutput(output.Params{})
+		require.NoError(t, err)
+
+		opts := exporterOptions{Protocol: FileExporterProtocol, Path: t.TempDir() + "/spans.jsonl"}
+		processor, err := root.spanProcessor(opts, logrus.New())
+		require.NoError(t, err)
+
+		processor.OnEnd(testSetup.VU, &Span{TraceID: testTraceID, SpanID: testSpanID})
+		require.NoError(t, root.output.Stop())
+
+		assert.Equal(t, uint64(1), atomic.LoadUint64(&processor.exported))
+		assert.Empty(t, root.processors)
+	})
+
+	t.Run("VUs initialized once the test ended should shut the processors down", func(t *testing.T) {
+		t.Parallel()
+
+		root, tracing, execution, samples := newTestRootModule(t, lib.RuntimeOptions{})
+
+		// VUs initialized while the test runs leave the processors be.
+		root.NewModuleInstance(modulestest.NewRuntime(t).VU)
+		tracing.exportSpans(&Span{TraceID: testTraceID, SpanID: testSpanID})
+		root.NewModuleInstance(modulestest.NewRuntime(t).VU)
+		assert.Len(t, root.processors, 1)
+
+		execution.MarkEnded()
+		root.NewModuleInstance(modulestest.NewRuntime(t).VU)
+
+		assert.Empty(t, root.processors)
+		assert.Equal(t, float64(1), sumSamples(samples, spansExportedMetricName))
+	})
+
+	t.Run("processors should be shut down once the test ended without summary", func(t *testing.T) {
+		t.Parallel()
+
+		_, tracing, execution, _ := newTestRootModule(t, lib.RuntimeOptions{NoSummary: null.BoolFrom(true)})
+		processor := tracing.processor
+
+		tracing.exportSpans(&Span{TraceID: testTraceID, SpanID: testSpanID})
+		execution.MarkEnded()
+
+		assert.Eventually(t, func() bool {
+			return atomic.LoadUint64(&processor.exported) == 1
+		}, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,30 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSpanID(t *testing.T) {
+	t.Parallel()
+
+	var withZero int
+	for i := 0; i < 100; i++ {
+		spanID, err := NewSpanID()
+		require.NoError(t, err)
+
+		decoded, err := hex.DecodeString(spanID)
+		require.NoError(t, err)
+		assert.Len(t, decoded, 8)
+
+		if strings.Contains(spanID, "0") {
+			withZero++
+		}
+	}
+
+	assert.NotZero(t, withZero, "span IDs should use every hex digit")
+}
@@ -12,6 +12,7 @@ const (
 	k6CloudCode            = 12    // To ingest and process the related spans in k6 Cloud.
 	k6LocalCode            = 33    // To not ingest and process the related spans, b/c they are part of a non-cloud run.
 	metadataTraceIDKeyName = "trace_id"
+	metadataSpanIDKeyName  = "span_id"
 )
 
 // Tracer is the interface that wraps the TraceID method.
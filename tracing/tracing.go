@@ -2,19 +2,64 @@ package tracing
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
+	k6http "go.k6.io/k6/js/modules/k6/http"
 	"go.k6.io/k6/metrics"
 )
 
 // Tracing is the JS module instance that will be created for each VU.
 type Tracing struct {
-	vu modules.VU
+	vu   modules.VU
+	root *RootModule
 
 	propagator Propagator
+	sampler    Sampler
+	processor  *batchSpanProcessor
+
+	// baggage is the baggage propagated alongside the span context
+	// of each request.
+	baggage Baggage
+
+	// traceState is the W3C trace state propagated alongside the span
+	// context of each request.
+	traceState string
+
+	// traceScope defines whether each request produces its own trace,
+	// or whether the requests of an iteration share the same trace.
+	traceScope string
+
+	// iteration is the trace of the VU's current iteration, when
+	// traces are scoped to iterations.
+	iteration *iterationTrace
+
+	// spans are the spans started from the script, and not ended yet,
+	// in the order they were started in. The last one is the active span.
+	spans []*scriptSpan
+
+	// httpMethods are the original methods of the k6/http module, once
+	// looked up.
+	httpMethods map[k6HTTPMethodName]goja.Callable
+
+	// parent is the module instance a tracing client was created from.
+	// It is nil for the module instance itself.
+	parent *Tracing
+}
+
+// module returns the VU's module instance, which holds the state shared
+// with the tracing clients created from it, such as the VU's active spans
+// and iteration trace.
+func (t *Tracing) module() *Tracing {
+	if t.parent != nil {
+		return t.parent
+	}
+
+	return t
 }
 
 // InstrumentHTTP instruments the HTTP module with tracing headers.
@@ -36,24 +81,12 @@ func (t *Tracing) InstrumentHTTP(options instrumentationOptions) {
 
 	httpModuleObj := httpModuleValue.ToObject(t.vu.Runtime())
 
-	HTTPMethods := [...]k6HTTPMethodName{
-		k6HTTPDeleteMethodName,
-		k6HTTPGetMethodName,
-		k6HTTPHeadMethodName,
-		k6HTTPOptionsMethodName,
-		k6HTTPPatchMethodName,
-		k6HTTPPostMethodName,
-		k6HTTPPutMethodName,
+	methods, err := t.k6HTTPMethods()
+	if err != nil {
+		common.Throw(t.vu.Runtime(), err)
 	}
 
-	for _, method := range HTTPMethods {
-		originalMethodFn, ok := goja.AssertFunction(httpModuleObj.Get(string(method)))
-		if !ok {
-			common.Throw(t.vu.Runtime(), fmt.Errorf("http.%s is not a function", method))
-		}
-
-		tracedMethodFn := t.instrumentHTTPMethod(method, originalMethodFn)
-
+	for method, tracedMethodFn := range t.instrumentHTTPMethods(methods) {
 		// Inject the new get function, adding tracing headers
 		// to the request in the HTTP module object.
 		err = httpModuleObj.Set(string(method), tracedMethodFn)
@@ -70,33 +103,172 @@ func (t *Tracing) InstrumentHTTP(options instrumentationOptions) {
 	}
 }
 
+// k6HTTPMethods returns the original methods of the k6/http module,
+// indexed by name.
+//
+// The methods are looked up once, before any of them is instrumented,
+// so that tracing clients can wrap the original methods, whether the
+// http module was instrumented in place or not.
+func (t *Tracing) k6HTTPMethods() (map[k6HTTPMethodName]goja.Callable, error) {
+	module := t.module()
+	if module.httpMethods != nil {
+		return module.httpMethods, nil
+	}
+
+	httpModuleObj, err := requireModule(t.vu.Runtime(), "k6/http")
+	if err != nil {
+		return nil, err
+	}
+
+	methods := make(map[k6HTTPMethodName]goja.Callable, len(k6HTTPMethodNames))
+	for _, method := range k6HTTPMethodNames {
+		methodValue := httpModuleObj.Get(string(method))
+
+		// The http.asyncRequest method was introduced in k6 v0.43.0, and
+		// is thus only instrumented if the running k6 version exposes it.
+		if method == k6HTTPAsyncRequestMethodName && isNullish(methodValue) {
+			continue
+		}
+
+		methodFn, ok := goja.AssertFunction(methodValue)
+		if !ok {
+			return nil, fmt.Errorf("http.%s is not a function", method)
+		}
+
+		methods[method] = methodFn
+	}
+
+	module.httpMethods = methods
+
+	return methods, nil
+}
+
+// instrumentHTTPMethods returns instrumented versions of the given
+// k6/http module methods, indexed by name.
+func (t *Tracing) instrumentHTTPMethods(methods map[k6HTTPMethodName]goja.Callable) map[k6HTTPMethodName]goja.Callable {
+	tracedMethods := make(map[k6HTTPMethodName]goja.Callable, len(methods))
+	for method, methodFn := range methods {
+		if method == k6HTTPBatchMethodName {
+			tracedMethods[method] = t.instrumentHTTPBatch(methodFn)
+			continue
+		}
+
+		tracedMethods[method] = t.instrumentHTTPMethod(method, methodFn)
+	}
+
+	return tracedMethods
+}
+
 // configure configures the tracing module with the given options.
 func (t *Tracing) configure(opts instrumentationOptions) error {
-	switch opts.Propagator {
-	case "w3c":
-		t.propagator = &W3CPropagator{}
-	case "b3":
-		t.propagator = &B3Propagator{}
-	case "jaeger":
-		t.propagator = &JaegerPropagator{}
-	default:
-		return fmt.Errorf("unknown propagator: %s", opts.Propagator)
+	var err error
+	t.baggage, err = NewBaggage(opts.Baggage)
+	if err != nil {
+		return fmt.Errorf("invalid baggage: %w", err)
+	}
+
+	t.traceState, err = NewTraceState(opts.TraceState)
+	if err != nil {
+		return fmt.Errorf("invalid trace state: %w", err)
+	}
+
+	t.propagator, err = newPropagator(opts.Propagator, PropagatorOptions{Debug: opts.Debug, Runtime: t.vu.Runtime()})
+	if err != nil {
+		return err
+	}
+
+	t.traceScope, err = parseTraceScope(opts.TraceScope)
+	if err != nil {
+		return err
+	}
+
+	samplingRate := DefaultSamplingRate
+	if opts.Sampling != nil {
+		samplingRate = *opts.Sampling
+	}
+
+	sampler, err := NewProbabilisticSampler(samplingRate)
+	if err != nil {
+		return err
+	}
+
+	t.sampler = sampler
+
+	if opts.Exporter != nil {
+		t.processor, err = t.root.spanProcessor(*opts.Exporter, t.logger())
+		if err != nil {
+			return fmt.Errorf("invalid exporter: %w", err)
+		}
 	}
 
 	return nil
 }
 
+// instrumentationTracing returns the tracing instance a k6 module is
+// instrumented with by the given instrumentation method.
+//
+// When options are given, the module is instrumented with its own
+// configuration, as tracing clients are, leaving the configuration set by
+// InstrumentHTTP untouched. Otherwise, the configuration set by a previous
+// InstrumentHTTP call is used.
+func (t *Tracing) instrumentationTracing(method string, options *instrumentationOptions) (*Tracing, error) {
+	if options == nil {
+		if t.propagator == nil {
+			return nil, fmt.Errorf("%s requires options, unless instrumentHTTP was called first", method)
+		}
+
+		return t, nil
+	}
+
+	tracing := &Tracing{
+		vu:     t.vu,
+		root:   t.root,
+		parent: t.module(),
+	}
+
+	if err := tracing.configure(*options); err != nil {
+		return nil, err
+	}
+
+	return tracing, nil
+}
+
 // instrumentationOptions are the options that can be passed to the
 // tracing.instrument() method.
 type instrumentationOptions struct {
-	// Sampling is the sampling rate to use for the tracer.
-	Sampling float64 `js:"sampling"`
-
-	// Propagation is the propagation format to use for the tracer.
-	Propagator string `js:"propagator"`
-
-	// Baggage is a map of baggage items to add to the tracer.
-	Baggage map[string]string `js:"baggage"`
+	// Sampling is the sampling rate to use for the tracer, expressed
+	// as a ratio between 0 and 1. When left unset, every trace is sampled.
+	Sampling *float64 `js:"sampling"`
+
+	// Propagator is the propagation format to use for the tracer, either
+	// a propagator name, a function returning the headers to send, or a
+	// list of them, in which case the headers of every listed propagator
+	// are sent along with each request.
+	Propagator interface{} `js:"propagator"`
+
+	// Debug propagates traces as debug traces, forcing their sampling by
+	// the services supporting it. Only the B3 and Datadog propagators
+	// support it.
+	Debug bool `js:"debug"`
+
+	// Baggage is a map of baggage items to propagate alongside the
+	// trace context. Items are either strings, or objects holding a
+	// value string and an optional properties object.
+	Baggage map[string]interface{} `js:"baggage"`
+
+	// TraceState is the W3C trace state to propagate alongside the trace
+	// context, as a tracestate header value holding comma-separated
+	// vendor=value list-members. Only the W3C propagator propagates it.
+	TraceState string `js:"traceState"`
+
+	// TraceScope defines whether each request produces its own trace,
+	// or whether the requests of an iteration share the same trace,
+	// either request, or iteration. Defaults to request.
+	TraceScope string `js:"traceScope"`
+
+	// Exporter configures how the client spans of the instrumented
+	// requests are exported. When left unset, no span is exported.
+	Exporter *exporterOptions `js:"exporter"`
 }
 
 // instrumentHTTPMethod returns a new function that wraps the original http
@@ -105,6 +277,11 @@ type instrumentationOptions struct {
 // The function takes the HTTP method name as argument, as well as the exported
 // method itself, as a goja.Callable.
 //
+// Note that the http.asyncRequest method captures the VU's tags and metadata
+// synchronously, before returning its promise. The trace ID metadata set
+// for the duration of the call thus remains attached to the request it was
+// produced for, even though it is removed before the promise resolves.
+//
 // The produced resulting function is ready to be injected back into the http
 // module, in place of the original method.
 func (t *Tracing) instrumentHTTPMethod(methodName k6HTTPMethodName, methodFn goja.Callable) goja.Callable {
@@ -125,51 +302,263 @@ func (t *Tracing) instrumentHTTPMethod(methodName k6HTTPMethodName, methodFn goj
 			common.Throw(rt, fmt.Errorf("failed to normalize HTTP headers: %w", err))
 		}
 
-		traceID := NewTraceID(k6Prefix, k6CloudCode, uint64(time.Now().UnixNano())/uint64(time.Millisecond))
-		encodedTraceID, _, err := traceID.Encode()
+		trace, err := t.injectTraceHeaders(headers)
 		if err != nil {
-			common.Throw(rt, fmt.Errorf("failed to encode trace ID: %w", err))
-		}
-
-		// Produce a trace header in the format defined by the
-		// configured propagator.
-		header, err := t.propagator.Propagate(encodedTraceID)
-		if err != nil {
-			common.Throw(rt, fmt.Errorf("failed to propagate trace ID: %w", err))
-		}
-
-		for key, value := range header {
-			err = headers.Set(key, value)
-			if err != nil {
-				common.Throw(rt, err)
-			}
+			common.Throw(rt, err)
 		}
 
 		vuState := t.vu.State()
 
-		// Add the trace ID to the VU's state, so that it can be
-		// used in the metrics emitted by the HTTP module.
+		// Add the trace and span IDs to the VU's state, so that they
+		// can be used in the metrics emitted by the HTTP module.
 		vuState.Tags.Modify(func(t *metrics.TagsAndMeta) {
-			t.SetMetadata(metadataTraceIDKeyName, encodedTraceID)
+			t.SetMetadata(metadataTraceIDKeyName, trace.TraceID)
+			t.SetMetadata(metadataSpanIDKeyName, trace.SpanID)
 		})
 
 		// call the original http.get method, with overridden arguments
 		args = append([]goja.Value{this}, args...)
 		result, err := methodFn(goja.Undefined(), args...)
-		if err != nil {
-			common.Throw(rt, err)
-		}
 
-		// Remove the trace ID from the VU's state, so that it doesn't
-		// leak into other requests.
+		// Remove the trace and span IDs from the VU's state, so that
+		// they don't leak into other requests, even when this one failed.
 		vuState.Tags.Modify(func(t *metrics.TagsAndMeta) {
 			t.DeleteMetadata(metadataTraceIDKeyName)
+			t.DeleteMetadata(metadataSpanIDKeyName)
 		})
 
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		// The http.asyncRequest method returns a promise, and its client
+		// span can only be recorded once the promise has settled.
+		if methodName == k6HTTPAsyncRequestMethodName {
+			return t.recordHTTPSpanOnSettled(trace, result), nil
+		}
+
+		t.recordHTTPSpan(trace, result, time.Now())
+
 		return result, err
 	}
 }
 
+// recordHTTPSpanOnSettled records the client span of an instrumented
+// http.asyncRequest call once the promise it returned has settled.
+//
+// It returns a new promise, settled with the same value as the original
+// one, once the span has been recorded. Returning a new promise, rather
+// than chaining on the original one, ensures that rejections remain
+// reported to the user when left unhandled.
+func (t *Tracing) recordHTTPSpanOnSettled(trace *requestTrace, promise goja.Value) goja.Value {
+	rt := t.vu.Runtime()
+
+	thenFn, ok := goja.AssertFunction(promise.ToObject(rt).Get("then"))
+	if !ok {
+		return promise
+	}
+
+	tracedPromise, resolve, reject := rt.NewPromise()
+
+	onFulfilled := func(response goja.Value) {
+		t.recordHTTPSpan(trace, response, time.Now())
+		resolve(response)
+	}
+
+	onRejected := func(reason goja.Value) {
+		if trace.IsSampled() {
+			t.exportSpans(&Span{
+				TraceID:      trace.TraceID,
+				SpanID:       trace.SpanID,
+				ParentSpanID: trace.ParentSpanID,
+				Name:         "HTTP",
+				Kind:         SpanKindClient,
+				StartTime:    trace.startTime,
+				EndTime:      time.Now(),
+				Status:       SpanStatus{Code: SpanStatusError, Message: reason.String()},
+			})
+		}
+		reject(reason)
+	}
+
+	if _, err := thenFn(promise, rt.ToValue(onFulfilled), rt.ToValue(onRejected)); err != nil {
+		common.Throw(rt, err)
+	}
+
+	return rt.ToValue(tracedPromise)
+}
+
+// recordHTTPSpan records the client span of an instrumented HTTP request,
+// from the k6 response it produced.
+//
+// Spans are only recorded for sampled requests, and when an exporter is
+// configured.
+func (t *Tracing) recordHTTPSpan(trace *requestTrace, response goja.Value, endTime time.Time) {
+	if t.processor == nil || !trace.IsSampled() || isNullish(response) {
+		return
+	}
+
+	res, ok := response.Export().(*k6http.Response)
+	if !ok || res.Response == nil {
+		return
+	}
+
+	t.exportSpans(newHTTPClientSpan(trace, res.Response, endTime))
+}
+
+// exportSpans hands the given spans to the configured span processor,
+// which exports them asynchronously.
+func (t *Tracing) exportSpans(spans ...*Span) {
+	if t.processor == nil {
+		return
+	}
+
+	t.root.watchTestRun(t.vu, t.logger())
+	t.processor.OnEnd(t.vu, spans...)
+}
+
+// logger returns the logger of the VU, whether it runs
+// in the init context, or in the VU context.
+func (t *Tracing) logger() logrus.FieldLogger {
+	if state := t.vu.State(); state != nil {
+		return state.Logger
+	}
+
+	return t.vu.InitEnv().Logger
+}
+
+// requestTrace holds the trace context produced for a single
+// instrumented request.
+type requestTrace struct {
+	// SpanContext is the span context of the request's client span, whose
+	// parent is the span that was active when the request was made, if any.
+	SpanContext
+
+	// startTime is the time at which the request was instrumented.
+	startTime time.Time
+}
+
+// injectTraceHeaders generates a new span ID, and sets the trace context
+// headers produced by the configured propagator in the given headers object.
+//
+// The request joins the trace returned by traceContext.
+//
+// It returns the produced trace context.
+func (t *Tracing) injectTraceHeaders(headers *goja.Object) (*requestTrace, error) {
+	trace, header, err := t.newRequestTrace()
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range header {
+		if err = headers.Set(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	trace.startTime = time.Now()
+
+	return trace, nil
+}
+
+// newRequestTrace generates the span context of a new request, joining
+// the trace returned by traceContext, and returns it along with the trace
+// context headers produced by the configured propagator.
+func (t *Tracing) newRequestTrace() (*requestTrace, http.Header, error) {
+	traceID, parentSpanID, sampled, err := t.traceContext()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	spanID, err := NewSpanID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trace := &requestTrace{
+		SpanContext: SpanContext{
+			TraceID:      traceID,
+			SpanID:       spanID,
+			ParentSpanID: parentSpanID,
+			TraceFlags:   newTraceFlags(sampled),
+			TraceState:   t.traceState,
+			Baggage:      t.baggage,
+		},
+	}
+
+	// Produce a trace header in the format defined by the
+	// configured propagator.
+	header, err := t.propagator.Propagate(trace.SpanContext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to propagate span context: %w", err)
+	}
+
+	return trace, header, nil
+}
+
+// traceContext returns the trace new spans and requests join, along with
+// the ID of their parent span, if any, and whether the trace is sampled.
+//
+// When a span is active, they join its trace as its children. Otherwise,
+// when traces are scoped to iterations, they join the trace of the current
+// iteration as children of its root span. Otherwise, they start a new trace.
+func (t *Tracing) traceContext() (traceID string, parentSpanID string, sampled bool, err error) {
+	if parent := t.activeSpan(); parent != nil {
+		return parent.span.TraceID, parent.span.SpanID, parent.sampled, nil
+	}
+
+	if t.traceScope == TraceScopeIteration {
+		var iteration *iterationTrace
+		iteration, err = t.currentIterationTrace()
+		if err != nil {
+			return "", "", false, err
+		}
+
+		if iteration != nil {
+			return iteration.span.TraceID, iteration.span.SpanID, iteration.sampled, nil
+		}
+	}
+
+	traceID, err = t.newTraceID()
+	if err != nil {
+		return "", "", false, err
+	}
+
+	return traceID, "", t.shouldSample(), nil
+}
+
+// shouldSample returns whether a new trace should be sampled, according
+// to the configured sampler. Traces are sampled when no sampler is
+// configured yet.
+func (t *Tracing) shouldSample() bool {
+	if t.sampler == nil {
+		return true
+	}
+
+	return t.sampler.ShouldSample()
+}
+
+// newTraceID returns a new hex encoded trace ID, generated by the
+// configured propagator when its format constrains trace IDs.
+func (t *Tracing) newTraceID() (string, error) {
+	if generator, ok := t.propagator.(traceIDGenerator); ok {
+		return generator.newTraceID()
+	}
+
+	return newEncodedTraceID()
+}
+
+// newEncodedTraceID returns a new hex encoded trace ID.
+func newEncodedTraceID() (string, error) {
+	traceID := NewTraceID(k6Prefix, k6CloudCode, uint64(time.Now().UnixNano())/uint64(time.Millisecond))
+	encodedTraceID, _, err := traceID.Encode()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode trace ID: %w", err)
+	}
+
+	return encodedTraceID, nil
+}
+
 // getOrCreateParams ensures that the HTTP method arguments list contains
 // a params object. If it doesn't, it creates one.
 //
@@ -180,6 +569,10 @@ func (t *Tracing) instrumentHTTPMethod(methodName k6HTTPMethodName, methodFn goj
 // as it can be called with 0, 1 or 2 arguments, and the second argument
 // can be either a request's body, or a params object.
 func (t *Tracing) getOrCreateParams(m k6HTTPMethodName, args ...goja.Value) ([]goja.Value, *goja.Object, error) {
+	if m == k6HTTPRequestMethodName || m == k6HTTPAsyncRequestMethodName {
+		return t.getOrCreateRequestParams(m, args...)
+	}
+
 	rt := t.vu.Runtime()
 	params := rt.NewObject()
 
@@ -210,6 +603,13 @@ func (t *Tracing) getOrCreateParams(m k6HTTPMethodName, args ...goja.Value) ([]g
 
 		args[0] = params
 	case 0:
+		// The http.get and the http.head methods take params as first
+		// argument, whereas the other methods take a request's body.
+		if m == k6HTTPGetMethodName || m == k6HTTPHeadMethodName {
+			args = []goja.Value{params}
+			break
+		}
+
 		args = []goja.Value{goja.Null(), params}
 	default:
 		return args, params, fmt.Errorf("unexpected number of arguments for http.%s method", m)
@@ -218,6 +618,39 @@ func (t *Tracing) getOrCreateParams(m k6HTTPMethodName, args ...goja.Value) ([]g
 	return args, params, nil
 }
 
+// getOrCreateRequestParams ensures that the http.request and http.asyncRequest
+// methods arguments list contains a params object. If it doesn't, it creates one.
+//
+// Those methods take the HTTP method as first argument, which is not part of
+// the arguments list, followed by the url, an optional body, and an optional
+// params object. The params object is thus always expected to be found as the
+// third element of the arguments list.
+func (t *Tracing) getOrCreateRequestParams(
+	m k6HTTPMethodName, args ...goja.Value,
+) ([]goja.Value, *goja.Object, error) {
+	rt := t.vu.Runtime()
+	params := rt.NewObject()
+
+	switch len(args) {
+	case 3:
+		paramsValue := args[2]
+		if !isNullish(paramsValue) {
+			params = paramsValue.ToObject(rt)
+			break
+		}
+
+		args[2] = params
+	case 2:
+		args = append(args, params)
+	case 1:
+		args = append(args, goja.Null(), params)
+	default:
+		return args, params, fmt.Errorf("unexpected number of arguments for http.%s method", m)
+	}
+
+	return args, params, nil
+}
+
 // getOrCreateHeaders ensures that a http method params object is properly
 // formed, and has the expected properties set.
 //
@@ -250,6 +683,22 @@ const (
 	k6HTTPPatchMethodName   k6HTTPMethodName = "patch"
 	k6HTTPHeadMethodName    k6HTTPMethodName = "head"
 	k6HTTPOptionsMethodName k6HTTPMethodName = "options"
+
+	k6HTTPRequestMethodName      k6HTTPMethodName = "request"
+	k6HTTPAsyncRequestMethodName k6HTTPMethodName = "asyncRequest"
+	k6HTTPBatchMethodName        k6HTTPMethodName = "batch"
 )
 
-// HTTPMethods is a static list of all the k6 HTTP method names.
+// k6HTTPMethodNames is a static list of all the k6 HTTP method names.
+var k6HTTPMethodNames = [...]k6HTTPMethodName{
+	k6HTTPDeleteMethodName,
+	k6HTTPGetMethodName,
+	k6HTTPHeadMethodName,
+	k6HTTPOptionsMethodName,
+	k6HTTPPatchMethodName,
+	k6HTTPPostMethodName,
+	k6HTTPPutMethodName,
+	k6HTTPRequestMethodName,
+	k6HTTPAsyncRequestMethodName,
+	k6HTTPBatchMethodName,
+}
@@ -200,6 +200,21 @@ func TestTracingGetOrCreateParams(t *testing.T) {
 		// hood.
 		args, params, gotErr := tracing.getOrCreateParams(k6HTTPGetMethodName)
 
+		assert.NoError(t, gotErr)
+		assert.NotNil(t, params)
+		assert.NotNil(t, args)
+		assert.Len(t, args, 1)
+		assert.Equal(t, args[0], params)
+	})
+
+	t.Run("no arguments should initialize a body and params argument", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU}
+
+		args, params, gotErr := tracing.getOrCreateParams(k6HTTPPostMethodName)
+
 		assert.NoError(t, gotErr)
 		assert.NotNil(t, params)
 		assert.NotNil(t, args)
@@ -209,6 +224,91 @@ func TestTracingGetOrCreateParams(t *testing.T) {
 	})
 }
 
+func TestTracingGetOrCreateRequestParams(t *testing.T) {
+	t.Parallel()
+
+	t.Run("a provided url, body and params leaves params untouched", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU}
+		url := testSetup.VU.Runtime().ToValue("https://k6.io")
+		body := testSetup.VU.Runtime().NewObject()
+		params := testSetup.VU.Runtime().NewObject()
+
+		gotArgs, gotParams, gotErr := tracing.getOrCreateParams(k6HTTPRequestMethodName, url, body, params)
+
+		assert.NoError(t, gotErr)
+		assert.Len(t, gotArgs, 3)
+		assert.Equal(t, url, gotArgs[0])
+		assert.Equal(t, body, gotArgs[1])
+		assert.Equal(t, params, gotArgs[2])
+		assert.True(t, gotParams == params)
+	})
+
+	t.Run("a provided url, body and null params intializes params", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU}
+		url := testSetup.VU.Runtime().ToValue("https://k6.io")
+		body := testSetup.VU.Runtime().NewObject()
+
+		gotArgs, gotParams, gotErr := tracing.getOrCreateParams(k6HTTPAsyncRequestMethodName, url, body, goja.Null())
+
+		assert.NoError(t, gotErr)
+		assert.NotNil(t, gotParams)
+		assert.Len(t, gotArgs, 3)
+		assert.Equal(t, body, gotArgs[1])
+		assert.Equal(t, gotParams, gotArgs[2])
+	})
+
+	t.Run("a provided url and body intializes params", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU}
+		url := testSetup.VU.Runtime().ToValue("https://k6.io")
+		body := testSetup.VU.Runtime().NewObject()
+
+		gotArgs, gotParams, gotErr := tracing.getOrCreateParams(k6HTTPRequestMethodName, url, body)
+
+		assert.NoError(t, gotErr)
+		assert.NotNil(t, gotParams)
+		assert.Len(t, gotArgs, 3)
+		assert.Equal(t, body, gotArgs[1])
+		assert.Equal(t, gotParams, gotArgs[2])
+	})
+
+	t.Run("a provided url only intializes body and params", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU}
+		url := testSetup.VU.Runtime().ToValue("https://k6.io")
+
+		gotArgs, gotParams, gotErr := tracing.getOrCreateParams(k6HTTPAsyncRequestMethodName, url)
+
+		assert.NoError(t, gotErr)
+		assert.NotNil(t, gotParams)
+		assert.Len(t, gotArgs, 3)
+		assert.Equal(t, url, gotArgs[0])
+		assert.Equal(t, goja.Null(), gotArgs[1])
+		assert.Equal(t, gotParams, gotArgs[2])
+	})
+
+	t.Run("no url should fail", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU}
+
+		_, _, gotErr := tracing.getOrCreateParams(k6HTTPRequestMethodName)
+
+		assert.Error(t, gotErr)
+	})
+}
+
 func TestTracingGetOrCreateHeaders(t *testing.T) {
 	t.Parallel()
 
@@ -274,3 +374,45 @@ func TestTracingGetOrCreateHeaders(t *testing.T) {
 		assert.NotNil(t, gotHeaders)
 	})
 }
+
+func TestTracingInstrumentationTracing(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing options should fail unless instrumentHTTP was called", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU}
+
+		_, gotErr := tracing.instrumentationTracing("instrumentGRPC", nil)
+
+		assert.Error(t, gotErr)
+	})
+
+	t.Run("missing options should use the module's configuration", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU, propagator: &W3CPropagator{}}
+
+		got, gotErr := tracing.instrumentationTracing("instrumentGRPC", nil)
+
+		assert.NoError(t, gotErr)
+		assert.Same(t, tracing, got)
+	})
+
+	t.Run("options should configure a child leaving the module untouched", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		propagator := &W3CPropagator{}
+		tracing := &Tracing{vu: testSetup.VU, propagator: propagator}
+
+		got, gotErr := tracing.instrumentationTracing("instrumentGRPC", &instrumentationOptions{Propagator: "b3"})
+
+		require.NoError(t, gotErr)
+		assert.Same(t, tracing, got.parent)
+		assert.IsType(t, &B3Propagator{}, got.propagator)
+		assert.Same(t, propagator, tracing.propagator)
+	})
+}
@@ -0,0 +1,483 @@
+package tracing
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	k6ws "go.k6.io/k6/js/modules/k6/ws"
+	"go.k6.io/k6/metrics"
+)
+
+// k6WebSocketsModuleName is the name of the k6 experimental
+// WebSockets module.
+const k6WebSocketsModuleName = "k6/experimental/websockets"
+
+// k6 WebSocket modules names.
+const (
+	k6WSConnectMethodName          = "connect"
+	k6WSOnMethodName               = "on"
+	k6WSSendMethodName             = "send"
+	k6WSSendBinaryMethodName       = "sendBinary"
+	k6WebSocketConstructorName     = "WebSocket"
+	k6WebSocketAddListenerName     = "addEventListener"
+	k6WebSocketParamsHeadersName   = "headers"
+	k6WebSocketMessageEventName    = "message"
+	k6WebSocketBinaryMessageName   = "binaryMessage"
+	k6WebSocketErrorEventName      = "error"
+	k6WebSocketCloseEventName      = "close"
+	k6WebSocketEventDataName       = "data"
+	k6WebSocketErrorEventErrorName = "error"
+)
+
+// webSocketSessionSpanName is the name of WebSocket session spans.
+const webSocketSessionSpanName = "WebSocket"
+
+// WebSocket messages span events name and attribute keys, following the
+// OpenTelemetry semantic conventions for RPC messages.
+const (
+	messageEventName                    = "message"
+	messageTypeAttributeKey             = "message.type"
+	messageUncompressedSizeAttributeKey = "message.uncompressed_size"
+
+	messageTypeSent     = "SENT"
+	messageTypeReceived = "RECEIVED"
+)
+
+// InstrumentWebSockets instruments the k6/ws and the k6/experimental/websockets
+// modules with tracing headers.
+//
+// When options are given, the connections are traced with their own
+// configuration, leaving the one set by InstrumentHTTP untouched.
+// Otherwise, the configuration set by a previous InstrumentHTTP call
+// is used.
+//
+// The connections opened by the ws.connect function, and by the WebSocket
+// constructor, after this call send the trace context headers produced by
+// the configured propagator along with their upgrade request. When an
+// exporter is configured, sampled connections also record a client span
+// covering the whole session, holding an event per message sent or received.
+func (t *Tracing) InstrumentWebSockets(options *instrumentationOptions) {
+	rt := t.vu.Runtime()
+
+	tracing, err := t.instrumentationTracing("instrumentWebSockets", options)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	// Explicitly inject the ws module in the VU's runtime, in order to
+	// override its connect function in place.
+	wsModuleValue, err := rt.RunString(`require('k6/ws')`)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	wsModuleObj := wsModuleValue.ToObject(rt)
+
+	connectFn, ok := goja.AssertFunction(wsModuleObj.Get(k6WSConnectMethodName))
+	if !ok {
+		common.Throw(rt, fmt.Errorf("ws.%s is not a function", k6WSConnectMethodName))
+	}
+
+	if err = wsModuleObj.Set(k6WSConnectMethodName, tracing.instrumentWSConnect(connectFn)); err != nil {
+		common.Throw(rt, err)
+	}
+
+	// The k6/experimental/websockets module is only shipped with some k6
+	// versions, and is thus only instrumented if the running k6 version
+	// exposes it.
+	webSocketsModuleValue, err := rt.RunString(`require('` + k6WebSocketsModuleName + `')`)
+	if err != nil {
+		if isUnknownModuleError(err, k6WebSocketsModuleName) {
+			return
+		}
+
+		common.Throw(rt, err)
+	}
+
+	webSocketsModuleObj := webSocketsModuleValue.ToObject(rt)
+
+	webSocketConstructor := webSocketsModuleObj.Get(k6WebSocketConstructorName)
+	if _, ok = goja.AssertConstructor(webSocketConstructor); !ok {
+		common.Throw(rt, fmt.Errorf("websockets.%s is not a constructor", k6WebSocketConstructorName))
+	}
+
+	err = webSocketsModuleObj.Set(k6WebSocketConstructorName, tracing.instrumentWebSocketConstructor(webSocketConstructor))
+	if err != nil {
+		common.Throw(rt, err)
+	}
+}
+
+// instrumentWSConnect returns a new function that wraps the original
+// ws.connect function, adding tracing headers to the upgrade request.
+//
+// The ws.connect function takes an url, an optional params object, and
+// a callback receiving the socket. It only returns once the socket is
+// closed, at which point the session span ends.
+func (t *Tracing) instrumentWSConnect(connectFn goja.Callable) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		rt := t.vu.Runtime()
+
+		url, paramsValue, callback := call.Argument(0), call.Argument(1), call.Argument(2)
+		if _, ok := goja.AssertFunction(paramsValue); ok {
+			paramsValue, callback = goja.Undefined(), paramsValue
+		}
+
+		params, trace, err := t.tracedWebSocketParams(paramsValue)
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		session := t.startWebSocketSession(trace, url.String())
+		if session != nil {
+			callback = session.instrumentWSCallback(callback)
+		}
+
+		vuState := t.vu.State()
+		if vuState != nil {
+			vuState.Tags.Modify(func(t *metrics.TagsAndMeta) {
+				t.SetMetadata(metadataTraceIDKeyName, trace.TraceID)
+				t.SetMetadata(metadataSpanIDKeyName, trace.SpanID)
+			})
+		}
+
+		result, err := connectFn(goja.Undefined(), url, params, callback)
+
+		if vuState != nil {
+			vuState.Tags.Modify(func(t *metrics.TagsAndMeta) {
+				t.DeleteMetadata(metadataTraceIDKeyName)
+				t.DeleteMetadata(metadataSpanIDKeyName)
+			})
+		}
+
+		if session != nil {
+			if err != nil {
+				session.recordError(err.Error())
+			}
+
+			if res, ok := exportOrNil(result).(*k6ws.HTTPResponse); ok {
+				session.span.Attributes[httpStatusCodeAttributeKey] = int64(res.Status)
+				if res.Error != "" {
+					session.recordError(res.Error)
+				}
+			}
+
+			session.end()
+		}
+
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		return result
+	}
+}
+
+// instrumentWebSocketConstructor returns a new constructor that wraps the
+// original WebSocket constructor, adding tracing headers to the upgrade
+// request.
+//
+// The WebSocket constructor takes an url, optional protocols, and an
+// optional params object. It returns before the connection is established,
+// and the session span ends once the connection is closed.
+func (t *Tracing) instrumentWebSocketConstructor(webSocketConstructor goja.Value) func(call goja.ConstructorCall) *goja.Object {
+	return func(call goja.ConstructorCall) *goja.Object {
+		rt := t.vu.Runtime()
+
+		url := call.Argument(0)
+
+		params, trace, err := t.tracedWebSocketParams(call.Argument(2))
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		// The WebSocket constructor captures the VU's tags and metadata
+		// synchronously, before the connection is established.
+		vuState := t.vu.State()
+		if vuState != nil {
+			vuState.Tags.Modify(func(t *metrics.TagsAndMeta) {
+				t.SetMetadata(metadataTraceIDKeyName, trace.TraceID)
+				t.SetMetadata(metadataSpanIDKeyName, trace.SpanID)
+			})
+		}
+
+		webSocket, err := rt.New(webSocketConstructor, url, call.Argument(1), params)
+
+		if vuState != nil {
+			vuState.Tags.Modify(func(t *metrics.TagsAndMeta) {
+				t.DeleteMetadata(metadataTraceIDKeyName)
+				t.DeleteMetadata(metadataSpanIDKeyName)
+			})
+		}
+
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		session := t.startWebSocketSession(trace, url.String())
+		if session == nil {
+			return webSocket
+		}
+
+		tracedWebSocket, err := session.instrumentWebSocket(webSocket)
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		return tracedWebSocket
+	}
+}
+
+// tracedWebSocketParams returns a copy of the given WebSocket params,
+// holding the trace context headers of a new request trace.
+//
+// The given params, and their headers object, are left untouched, so
+// that they can safely be reused by the script.
+func (t *Tracing) tracedWebSocketParams(params goja.Value) (*goja.Object, *requestTrace, error) {
+	rt := t.vu.Runtime()
+
+	tracedParams := copyObject(rt, params)
+
+	headers := copyObject(rt, tracedParams.Get(k6WebSocketParamsHeadersName))
+
+	trace, err := t.injectTraceHeaders(headers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = tracedParams.Set(k6WebSocketParamsHeadersName, headers); err != nil {
+		return nil, nil, err
+	}
+
+	return tracedParams, trace, nil
+}
+
+// webSocketSession records the client span of a WebSocket session.
+type webSocketSession struct {
+	tracing *Tracing
+	span    *Span
+	ended   bool
+}
+
+// startWebSocketSession starts the session span of an instrumented
+// WebSocket connection.
+//
+// It returns nil when the session isn't recorded, that is when the
+// connection isn't sampled, or when no exporter is configured.
+func (t *Tracing) startWebSocketSession(trace *requestTrace, url string) *webSocketSession {
+	if t.processor == nil || !trace.IsSampled() {
+		return nil
+	}
+
+	return &webSocketSession{
+		tracing: t,
+		span: &Span{
+			TraceID:      trace.TraceID,
+			SpanID:       trace.SpanID,
+			ParentSpanID: trace.ParentSpanID,
+			Name:         webSocketSessionSpanName,
+			Kind:         SpanKindClient,
+			StartTime:    trace.startTime,
+			Attributes: map[string]interface{}{
+				httpURLAttributeKey: url,
+			},
+		},
+	}
+}
+
+// instrumentWSCallback returns a new function that wraps the original
+// ws.connect callback, recording the messages of the socket it receives.
+//
+// As the sockets produced by k6 are backed by Go values, which can't be
+// modified, the callback receives a socket inheriting from the original
+// one, and only overriding its send methods.
+func (s *webSocketSession) instrumentWSCallback(callback goja.Value) goja.Value {
+	callbackFn, ok := goja.AssertFunction(callback)
+	if !ok {
+		// Let the ws module report the missing callback.
+		return callback
+	}
+
+	rt := s.tracing.vu.Runtime()
+
+	return rt.ToValue(func(call goja.FunctionCall) goja.Value {
+		socket := call.Argument(0).ToObject(rt)
+
+		onFn, ok := goja.AssertFunction(socket.Get(k6WSOnMethodName))
+		if !ok {
+			common.Throw(rt, fmt.Errorf("socket.%s is not a function", k6WSOnMethodName))
+		}
+
+		listeners := map[string]func(goja.Value){
+			k6WebSocketMessageEventName:  func(data goja.Value) { s.recordMessage(messageTypeReceived, data) },
+			k6WebSocketBinaryMessageName: func(data goja.Value) { s.recordMessage(messageTypeReceived, data) },
+			k6WebSocketErrorEventName:    func(err goja.Value) { s.recordError(err.String()) },
+		}
+
+		for event, listener := range listeners {
+			if _, err := onFn(socket, rt.ToValue(event), rt.ToValue(listener)); err != nil {
+				common.Throw(rt, err)
+			}
+		}
+
+		tracedSocket := rt.NewObject()
+		if err := tracedSocket.SetPrototype(socket); err != nil {
+			common.Throw(rt, err)
+		}
+
+		for _, method := range []string{k6WSSendMethodName, k6WSSendBinaryMethodName} {
+			if err := tracedSocket.Set(method, s.instrumentSend(socket, method)); err != nil {
+				common.Throw(rt, err)
+			}
+		}
+
+		result, err := callbackFn(call.This, tracedSocket)
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		return result
+	})
+}
+
+// instrumentWebSocket records the messages of the given WebSocket, and
+// ends the session span once it closes.
+//
+// As the WebSocket properties can't be redefined, it returns a WebSocket
+// inheriting from the original one, and only overriding its send method.
+func (s *webSocketSession) instrumentWebSocket(webSocket *goja.Object) (*goja.Object, error) {
+	rt := s.tracing.vu.Runtime()
+
+	addListenerFn, ok := goja.AssertFunction(webSocket.Get(k6WebSocketAddListenerName))
+	if !ok {
+		return nil, fmt.Errorf("WebSocket.%s is not a function", k6WebSocketAddListenerName)
+	}
+
+	listeners := map[string]func(goja.Value){
+		k6WebSocketMessageEventName: func(event goja.Value) {
+			s.recordMessage(messageTypeReceived, event.ToObject(rt).Get(k6WebSocketEventDataName))
+		},
+		k6WebSocketErrorEventName: func(event goja.Value) {
+			message := "WebSocket error"
+			if err := event.ToObject(rt).Get(k6WebSocketErrorEventErrorName); !isNullish(err) {
+				message = err.String()
+			}
+			s.recordError(message)
+		},
+		k6WebSocketCloseEventName: func(goja.Value) { s.end() },
+	}
+
+	for event, listener := range listeners {
+		if _, err := addListenerFn(webSocket, rt.ToValue(event), rt.ToValue(listener)); err != nil {
+			return nil, err
+		}
+	}
+
+	tracedWebSocket := rt.NewObject()
+	if err := tracedWebSocket.SetPrototype(webSocket); err != nil {
+		return nil, err
+	}
+
+	// The inherited send property is read-only, and is thus shadowed by
+	// defining a property, rather than by assigning one.
+	err := tracedWebSocket.DefineDataProperty(
+		k6WSSendMethodName, s.instrumentSend(webSocket, k6WSSendMethodName),
+		goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return tracedWebSocket, nil
+}
+
+// instrumentSend returns a new function that wraps the given send method
+// of a socket, recording the messages it sends.
+func (s *webSocketSession) instrumentSend(socket *goja.Object, method string) goja.Value {
+	rt := s.tracing.vu.Runtime()
+
+	sendFn, ok := goja.AssertFunction(socket.Get(method))
+	if !ok {
+		common.Throw(rt, fmt.Errorf("socket.%s is not a function", method))
+	}
+
+	return rt.ToValue(func(call goja.FunctionCall) goja.Value {
+		result, err := sendFn(socket, call.Arguments...)
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		s.recordMessage(messageTypeSent, call.Argument(0))
+
+		return result
+	})
+}
+
+// recordMessage records a message sent or received during the session
+// as a span event.
+func (s *webSocketSession) recordMessage(messageType string, data goja.Value) {
+	if s.ended {
+		return
+	}
+
+	attributes := map[string]interface{}{
+		messageTypeAttributeKey: messageType,
+	}
+
+	if size, ok := messageSize(data); ok {
+		attributes[messageUncompressedSizeAttributeKey] = size
+	}
+
+	s.span.Events = append(s.span.Events, SpanEvent{
+		Name:       messageEventName,
+		Time:       time.Now(),
+		Attributes: attributes,
+	})
+}
+
+// recordError sets the status of the session span to error.
+func (s *webSocketSession) recordError(message string) {
+	if s.ended {
+		return
+	}
+
+	s.span.Status = SpanStatus{Code: SpanStatusError, Message: message}
+}
+
+// end ends the session span, and exports it.
+//
+// Ending a session that already ended has no effect.
+func (s *webSocketSession) end() {
+	if s.ended {
+		return
+	}
+
+	s.ended = true
+	s.span.EndTime = time.Now()
+	s.tracing.exportSpans(s.span)
+}
+
+// messageSize returns the size in bytes of a WebSocket message,
+// either a string or an ArrayBuffer.
+func messageSize(data goja.Value) (int64, bool) {
+	switch message := exportOrNil(data).(type) {
+	case string:
+		return int64(len(message)), true
+	case goja.ArrayBuffer:
+		return int64(len(message.Bytes())), true
+	case *goja.ArrayBuffer:
+		return int64(len(message.Bytes())), true
+	default:
+		return 0, false
+	}
+}
+
+// exportOrNil exports the given value, or returns nil if it's nullish.
+func exportOrNil(value goja.Value) interface{} {
+	if isNullish(value) {
+		return nil
+	}
+
+	return value.Export()
+}
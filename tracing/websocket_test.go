@@ -0,0 +1,286 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k6ws "go.k6.io/k6/js/modules/k6/ws"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/metrics"
+)
+
+func TestTracingTracedWebSocketParams(t *testing.T) {
+	t.Parallel()
+
+	t.Run("trace context headers should be set in the params headers", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		tracing := &Tracing{vu: testSetup.VU, propagator: &W3CPropagator{}}
+
+		params, trace, err := tracing.tracedWebSocketParams(goja.Undefined())
+
+		require.NoError(t, err)
+		headers := params.Get(k6WebSocketParamsHeadersName).ToObject(testSetup.VU.Runtime())
+		assert.Equal(t, "00-"+trace.TraceID+"-"+trace.SpanID+"-01", headers.Get(W3CHeaderName).String())
+	})
+
+	t.Run("given params and headers should be left untouched", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup := modulestest.NewRuntime(t)
+		rt := testSetup.VU.Runtime()
+		tracing := &Tracing{vu: testSetup.VU, propagator: &W3CPropagator{}}
+
+		given, err := rt.RunString(`({tags: {name: "chat"}, headers: {"X-User": "bob"}})`)
+		require.NoError(t, err)
+
+		params, _, err := tracing.tracedWebSocketParams(given)
+
+		require.NoError(t, err)
+		assert.NotNil(t, params.Get("tags"))
+		headers := params.Get(k6WebSocketParamsHeadersName).ToObject(rt)
+		assert.Equal(t, "bob", headers.Get("X-User").String())
+		assert.NotNil(t, headers.Get(W3CHeaderName))
+
+		givenHeaders := given.ToObject(rt).Get(k6WebSocketParamsHeadersName).ToObject(rt)
+		assert.Equal(t, []string{"X-User"}, givenHeaders.Keys())
+	})
+}
+
+func TestTracingStartWebSocketSession(t *testing.T) {
+	t.Parallel()
+
+	testSetup := modulestest.NewRuntime(t)
+	tracing := &Tracing{vu: testSetup.VU}
+
+	session := tracing.startWebSocketSession(&requestTrace{SpanContext: SpanContext{TraceFlags: TraceFlagsSampled}}, "ws://localhost")
+
+	assert.Nil(t, session, "sessions should not be recorded without exporter")
+}
+
+func TestWebSocketSessionInstrumentWebSocket(t *testing.T) {
+	t.Parallel()
+
+	testSetup := modulestest.NewRuntime(t)
+	rt := testSetup.VU.Runtime()
+	tracing := &Tracing{vu: testSetup.VU}
+
+	session := &webSocketSession{
+		tracing: tracing,
+		span:    &Span{Name: webSocketSessionSpanName, Attributes: map[string]interface{}{}},
+	}
+
+	// A fake WebSocket, holding its listeners, and dispatching events.
+	fake, err := rt.RunString(`
+		const listeners = {};
+		const webSocket = {
+			sent: [],
+			addEventListener(event, listener) { (listeners[event] = listeners[event] || []).push(listener) },
+			dispatch(event, data) { (listeners[event] || []).forEach((listener) => listener(data)) },
+		};
+		Object.defineProperty(webSocket, "send", {value(message) { this.sent.push(message) }, enumerable: true});
+		webSocket
+	`)
+	require.NoError(t, err)
+
+	tracedWebSocket, err := session.instrumentWebSocket(fake.ToObject(rt))
+	require.NoError(t, err)
+	require.NoError(t, rt.Set("ws", tracedWebSocket))
+
+	_, err = rt.RunString(`
+		ws.send("hello");
+		ws.dispatch("message", {data: "hi there"});
+		ws.dispatch("error", {error: "connection reset"});
+		ws.dispatch("close", {});
+		ws.send("ignored");
+	`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []interface{}{"hello", "ignored"}, fake.ToObject(rt).Get("sent").Export())
+	assert.True(t, session.ended)
+	assert.False(t, session.span.EndTime.IsZero())
+	assert.Equal(t, SpanStatus{Code: SpanStatusError, Message: "connection reset"}, session.span.Status)
+
+	require.Len(t, session.span.Events, 2)
+	assert.Equal(t, map[string]interface{}{
+		messageTypeAttributeKey:             messageTypeSent,
+		messageUncompressedSizeAttributeKey: int64(5),
+	}, session.span.Events[0].Attributes)
+	assert.Equal(t, map[string]interface{}{
+		messageTypeAttributeKey:             messageTypeReceived,
+		messageUncompressedSizeAttributeKey: int64(8),
+	}, session.span.Events[1].Attributes)
+}
+
+func TestMessageSize(t *testing.T) {
+	t.Parallel()
+
+	rt := goja.New()
+
+	size, ok := messageSize(rt.ToValue("hello"))
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), size)
+
+	size, ok = messageSize(rt.ToValue(rt.NewArrayBuffer([]byte{1, 2, 3})))
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), size)
+
+	_, ok = messageSize(goja.Undefined())
+	assert.False(t, ok)
+}
+
+func TestTracingInstrumentWebSockets(t *testing.T) {
+	t.Parallel()
+
+	// newTestRuntime returns a test runtime resolving the k6/ws module,
+	// and failing to require the experimental websockets module with
+	// the given error message.
+	newTestRuntime := func(t *testing.T, webSocketsErr string) (*modulestest.Runtime, *goja.Object) {
+		t.Helper()
+
+		testSetup := modulestest.NewRuntime(t)
+		rt := testSetup.VU.Runtime()
+
+		wsModule := rt.ToValue(k6ws.New().NewModuleInstance(testSetup.VU).Exports().Default).ToObject(rt)
+		require.NoError(t, rt.Set("require", func(name string) goja.Value {
+			if name == k6WebSocketsModuleName {
+				panic(rt.NewGoError(errors.New(webSocketsErr)))
+			}
+
+			return wsModule
+		}))
+
+		return testSetup, wsModule
+	}
+
+	t.Run("a missing experimental websockets module should be skipped", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup, wsModule := newTestRuntime(t, "unknown module: "+k6WebSocketsModuleName)
+		tracing := &Tracing{vu: testSetup.VU, propagator: &W3CPropagator{}}
+		original := wsModule.Get(k6WSConnectMethodName)
+
+		assert.NotPanics(t, func() { tracing.InstrumentWebSockets(nil) })
+		assert.False(t, original.SameAs(wsModule.Get(k6WSConnectMethodName)))
+	})
+
+	t.Run("ws.connect sessions should carry their trace context", func(t *testing.T) {
+		t.Parallel()
+
+		headers := make(chan http.Header, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers <- r.Header.Clone()
+
+			conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close() //nolint:errcheck
+
+			// Echo a single message back.
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = conn.WriteMessage(messageType, message)
+			_, _, _ = conn.ReadMessage()
+		}))
+		t.Cleanup(srv.Close)
+
+		testSetup, _ := newTestRuntime(t, "unknown module: "+k6WebSocketsModuleName)
+		rt := testSetup.VU.Runtime()
+		require.NoError(t, rt.Set("url", "ws"+strings.TrimPrefix(srv.URL, "http")))
+
+		registry := testSetup.VU.InitEnv().Registry
+		m, err := registerSpanProcessorMetrics(registry)
+		require.NoError(t, err)
+
+		exporter := &recordingExporter{}
+		processor, err := newBatchSpanProcessor(exporter, nil, m, registry.RootTagSet(), logrus.New())
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = processor.Shutdown(context.Background(), nil) })
+
+		tracing := &Tracing{
+			vu:         testSetup.VU,
+			root:       New(),
+			propagator: &W3CPropagator{},
+			sampler:    &ProbabilisticSampler{rate: 1},
+			processor:  processor,
+		}
+		tracing.InstrumentWebSockets(nil)
+
+		moveToTestVUContext(t, testSetup)
+		testSetup.VU.StateField.Dialer = &net.Dialer{}
+		samples := make(chan metrics.SampleContainer, 1000)
+		testSetup.VU.StateField.Samples = samples
+
+		_, err = rt.RunString(`
+			require("k6/ws").connect(url, {headers: {"X-User": "bob"}}, (socket) => {
+				socket.on("open", () => socket.send("hello"));
+				socket.on("message", () => socket.close());
+			});
+		`)
+		require.NoError(t, err)
+
+		// The upgrade request carries the trace context headers.
+		got := <-headers
+		assert.Equal(t, "bob", got.Get("X-User"))
+		traceparent := strings.Split(got.Get(W3CHeaderName), "-")
+		require.Len(t, traceparent, 4)
+		traceID, spanID := traceparent[1], traceparent[2]
+
+		// The session's samples carry its trace metadata.
+		var sessions int
+		for len(samples) > 0 {
+			for _, sample := range (<-samples).GetSamples() {
+				if !strings.HasPrefix(sample.Metric.Name, "ws_") {
+					continue
+				}
+
+				assert.Equal(t, traceID, sample.Metadata[metadataTraceIDKeyName], sample.Metric.Name)
+				assert.Equal(t, spanID, sample.Metadata[metadataSpanIDKeyName], sample.Metric.Name)
+				if sample.Metric.Name == metrics.WSSessionsName {
+					sessions++
+				}
+			}
+		}
+		assert.Equal(t, 1, sessions)
+		assert.NotContains(t, testSetup.VU.State().Tags.GetCurrentValues().Metadata, metadataTraceIDKeyName)
+
+		// The session span ended, and was queued for export, by the
+		// time ws.connect returned.
+		processor.ForceFlush()
+		require.Equal(t, []int{1}, exporter.batchSizes())
+
+		span := exporter.batches[0][0]
+		assert.Equal(t, webSocketSessionSpanName, span.Name)
+		assert.Equal(t, traceID, span.TraceID)
+		assert.Equal(t, spanID, span.SpanID)
+		assert.False(t, span.EndTime.IsZero())
+		assert.Equal(t, int64(http.StatusSwitchingProtocols), span.Attributes[httpStatusCodeAttributeKey])
+
+		require.Len(t, span.Events, 2)
+		assert.Equal(t, messageTypeSent, span.Events[0].Attributes[messageTypeAttributeKey])
+		assert.Equal(t, messageTypeReceived, span.Events[1].Attributes[messageTypeAttributeKey])
+	})
+
+	t.Run("failing to require the experimental websockets module should throw", func(t *testing.T) {
+		t.Parallel()
+
+		testSetup, _ := newTestRuntime(t, "boom")
+		tracing := &Tracing{vu: testSetup.VU, propagator: &W3CPropagator{}}
+
+		assert.Panics(t, func() { tracing.InstrumentWebSockets(nil) })
+	})
+}